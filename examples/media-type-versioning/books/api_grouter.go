@@ -0,0 +1,12 @@
+package books
+
+import (
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+func SetupWithGRouter(router *routing.GRouter) {
+	router.Route("/books", func(r *routing.GRouter) {
+		r.Mount("/", BookingRouter())
+		r.Mount("/{id}/ratings", RatingRouter())
+	})
+}
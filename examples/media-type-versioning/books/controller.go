@@ -2,6 +2,7 @@ package books
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/athosone/golib/pkg/server/renderer"
 	"github.com/athosone/golib/pkg/server/routing"
@@ -17,18 +18,43 @@ const (
 	v2BookRating         = "application/vnd.athosone.book.rating+json; v=v2"
 )
 
+var v1Beta1DeprecationDate = time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+// bookVersions serves the beta versions from the V2Book handled by GetBooks.
+var bookVersions = newBookVersions()
+
+func newBookVersions() *renderer.Versions {
+	vs := renderer.NewVersions("v2")
+	renderer.Convert(vs, "v2", "v1beta2", func(b V2Book) (V1Beta2Book, error) {
+		rating := ""
+		if len(b.Ratings) > 0 {
+			rating = b.Ratings[0].Grade
+		}
+		return V1Beta2Book{Name: b.Name, Rating: rating}, nil
+	})
+	renderer.Convert(vs, "v1beta2", "v1beta1", func(b V1Beta2Book) (V1Beta2Book, error) {
+		return b, nil
+	})
+	return vs
+}
+
 func BookingRouter() *routing.GRouter {
 	bookingRouter := routing.NewRouter()
-	bookingRouter.Get(GetBetaBooks).Produce(v1Beta1BookMediaType, v1Beta2BookMediaType).SetDefault()
-	bookingRouter.Get(GetV2Books).Produce(v2BookMediaType)
+	bookingRouter.Get(GetBooks).Produce(v1Beta1BookMediaType, v1Beta2BookMediaType, v2BookMediaType).
+		Summary("Get a book").Response(http.StatusOK, V2Book{}).
+		Versions(bookVersions).
+		Deprecated(v1Beta1DeprecationDate, "/api/books", routing.ForMediaTypes(v1Beta1BookMediaType)).
+		SetDefault()
 
 	return bookingRouter
 }
 
 func RatingRouter() *routing.GRouter {
 	ratingRouter := routing.NewRouter()
-	ratingRouter.Post(PostAddRatingV2).Produce(v2BookRating).Consume(v1AddRating)
-	ratingRouter.Get(GetV2Ratings).Produce(v2BookRating)
+	ratingRouter.Post(PostAddRatingV2).Produce(v2BookRating).Consume(v1AddRating).
+		Summary("Rate a book").Response(http.StatusCreated, V2Rating{})
+	ratingRouter.Get(GetV2Ratings).Produce(v2BookRating).
+		Summary("Get the rating of a book").Response(http.StatusOK, V2Rating{})
 
 	return ratingRouter
 }
@@ -47,27 +73,31 @@ type V2Rating struct {
 	Grade string `json:"grade"`
 }
 
-func GetBetaBooks(w http.ResponseWriter, r *http.Request) {
+// GetBooks only deals with V2Book, the beta versions are converted by the renderer.
+func GetBooks(w http.ResponseWriter, r *http.Request) {
 	// Long string to test content encoding
-	_ = renderer.Created(w, r, V1Beta2Book{Name: "lore ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat. Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat nulla pariatur. Excepteur sint occaecat cupidatat non proident, sunt in culpa qui officia deserunt mollit anim id est laborum."})
-}
-
-func GetV2Books(w http.ResponseWriter, r *http.Request) {
-	_ = renderer.OK(w, r, V2Book{Name: "GET V2"})
+	_ = renderer.OK(w, r, V2Book{
+		Name:    "lore ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat. Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat nulla pariatur. Excepteur sint occaecat cupidatat non proident, sunt in culpa qui officia deserunt mollit anim id est laborum.",
+		Ratings: []V2Rating{{Grade: "A"}},
+	})
 }
 
 func GetV2Ratings(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		id = mux.Vars(r)["id"]
-	}
+	id := bookID(r)
 	_ = renderer.OK(w, r, V2Rating{Grade: "get rating:" + id})
 }
 
 func PostAddRatingV2(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		id = mux.Vars(r)["id"]
-	}
+	id := bookID(r)
 	_ = renderer.Created(w, r, V2Rating{Grade: "post rating: " + id})
 }
+
+func bookID(r *http.Request) string {
+	if id := routing.URLParam(r, "id"); id != "" {
+		return id
+	}
+	if id := chi.URLParam(r, "id"); id != "" {
+		return id
+	}
+	return mux.Vars(r)["id"]
+}
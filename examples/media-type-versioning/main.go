@@ -8,6 +8,8 @@ import (
 	glogger "github.com/athosone/golib/pkg/logger"
 	"github.com/athosone/golib/pkg/server"
 	gmiddleware "github.com/athosone/golib/pkg/server/middleware"
+	"github.com/athosone/golib/pkg/server/openapi"
+	"github.com/athosone/golib/pkg/server/routing"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/mux"
@@ -19,9 +21,10 @@ var (
 	Version string = "dev"
 )
 
-// Will launch two servers based on two libraries:
+// Will launch three servers based on three libraries:
 // - Gorilla Mux: http://localhost:8080/api/books
 // - Go-Chi:      http://localhost:8081/api/books
+// - GRouter:     http://localhost:8082/api/books
 func main() {
 	// Init logger
 	logger = glogger.NewLogger(os.Getenv("IS_DEBUG") == "true").With("service", "sample-service").With("version", Version)
@@ -32,8 +35,9 @@ func main() {
 
 	routerMux := mux.NewRouter()
 	routerMux.Use(gmiddleware.CompressResponse())
+	routerMux.Use(gmiddleware.Correlation())
 	routerMux.Use(gmiddleware.InjectLoggerInRequest(func(r *http.Request) *zap.SugaredLogger {
-		return logger.With("request_id", middleware.GetReqID(r.Context())).With("router", "mux")
+		return logger.With("router", "mux")
 	}))
 	routerMux.Use(gmiddleware.RequestLogger([]string{"/healthy", "/ready"}))
 	routerMux.Use(middleware.Heartbeat("/healthy"))
@@ -44,14 +48,27 @@ func main() {
 	routerChi := chi.NewRouter()
 
 	routerChi.Use(gmiddleware.CompressResponse())
+	routerChi.Use(gmiddleware.Correlation())
 	routerChi.Use(gmiddleware.InjectLoggerInRequest(func(r *http.Request) *zap.SugaredLogger {
-		return logger.With("request_id", middleware.GetReqID(r.Context())).With("router", "chi")
+		return logger.With("router", "chi")
 	}))
 	routerChi.Use(gmiddleware.RequestLogger([]string{"/healthy", "/ready"}))
 	routerChi.Use(middleware.Heartbeat("/healthy"))
 
 	setupWithChi(routerChi)
 
+	// Setup GRouter server
+	gRouter := routing.NewRouter()
+	setupWithGRouter(gRouter)
+	var handlerGRouter http.Handler = gRouter
+	handlerGRouter = gmiddleware.Recover()(handlerGRouter)
+	handlerGRouter = gmiddleware.RequestLogger([]string{"/healthy", "/ready"})(handlerGRouter)
+	handlerGRouter = gmiddleware.InjectLoggerInRequest(func(r *http.Request) *zap.SugaredLogger {
+		return logger.With("router", "grouter")
+	})(handlerGRouter)
+	handlerGRouter = gmiddleware.Correlation()(handlerGRouter)
+	handlerGRouter = gmiddleware.CompressResponse()(handlerGRouter)
+
 	// Config server
 	srvMux := &http.Server{Addr: "0.0.0.0:8080", Handler: routerMux}
 	zap.S().Infow("Starting mux server", "addr", srvMux.Addr)
@@ -59,7 +76,11 @@ func main() {
 
 	srvChi := &http.Server{Addr: "0.0.0.0:8081", Handler: routerChi}
 	zap.S().Infow("Starting chi server", "addr", srvChi.Addr)
-	server.ListenAndServe(srvChi)
+	go server.ListenAndServe(srvChi)
+
+	srvGRouter := &http.Server{Addr: "0.0.0.0:8082", Handler: handlerGRouter}
+	zap.S().Infow("Starting grouter server", "addr", srvGRouter.Addr)
+	server.ListenAndServe(srvGRouter)
 }
 
 func setupWithMux(router *mux.Router) {
@@ -79,3 +100,18 @@ func setupWithChi(router chi.Router) {
 		books.SetupWithChi(r)
 	})
 }
+
+func setupWithGRouter(router *routing.GRouter) {
+	router.Route("/ready", func(r *routing.GRouter) {
+		r.Get(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}).SetDefault()
+	})
+	router.Route("/api", func(r *routing.GRouter) {
+		books.SetupWithGRouter(r)
+	})
+	doc := openapi.Generate(openapi.Info{Title: "books", Version: Version}, router)
+	router.Route("/openapi", func(r *routing.GRouter) {
+		r.Get(openapi.Handler(doc)).Produce("application/json", "application/yaml").SetDefault()
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/athosone/golib/pkg/telemetry"
 	oidc "github.com/coreos/go-oidc"
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2"
@@ -13,14 +14,26 @@ import (
 
 type AuthProvider struct {
 	tokenSource oauth2.TokenSource
+	telemetry   *telemetry.Telemetry
+	cache       cacheConfig
 }
 
 type AuthProviderFactory func(ctx context.Context) (*AuthProvider, error)
 
+// Option configures the auth provider created by a factory.
+type Option func(*AuthProvider)
+
+// WithTelemetry traces the token fetches with OpenTelemetry, see telemetry.New for the providers.
+func WithTelemetry(opts ...telemetry.Option) Option {
+	return func(auth *AuthProvider) {
+		auth.telemetry = telemetry.New(opts...)
+	}
+}
+
 // Returns a func that initialize a new auth provider which uses the client credentials flow
 // Scopes must be provided as space separated: e.g.: "openid profile email"
 // STS URL is expected to be the base url e.g: https://login.athosone.com
-func NewClientCredentialsFactory(clientID string, clientSecret string, stsURL string, scopes string) AuthProviderFactory {
+func NewClientCredentialsFactory(clientID string, clientSecret string, stsURL string, scopes string, opts ...Option) AuthProviderFactory {
 	return func(ctx context.Context) (*AuthProvider, error) {
 		stsURL = strings.Trim(stsURL, "/")
 		provider, err := oidc.NewProvider(ctx, stsURL)
@@ -36,9 +49,21 @@ func NewClientCredentialsFactory(clientID string, clientSecret string, stsURL st
 			AuthStyle:    oauth2.AuthStyleAutoDetect,
 		}
 
-		return &AuthProvider{
-			tokenSource: cfg.TokenSource(ctx),
-		}, nil
+		auth := &AuthProvider{cache: defaultCacheConfig()}
+		for _, opt := range opts {
+			opt(auth)
+		}
+		// tokens are cached and fetched again before they expire
+		auth.tokenSource = newCachingTokenSource(ctx, &fetchTokenSource{
+			auth:     auth,
+			tokenURL: cfg.TokenURL,
+			clientID: clientID,
+			fetch: func(ctx context.Context) (*oauth2.Token, error) {
+				return cfg.Token(ctx)
+			},
+			ctx: ctx,
+		}, auth.cache)
+		return auth, nil
 	}
 }
 
@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/athosone/golib/pkg/server/renderer"
+	oidc "github.com/coreos/go-oidc"
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// RFC 6750 error codes: https://www.rfc-editor.org/rfc/rfc6750#section-3.1
+const (
+	ErrorCodeInvalidRequest    = "invalid_request"
+	ErrorCodeInvalidToken      = "invalid_token"
+	ErrorCodeInsufficientScope = "insufficient_scope"
+)
+
+const HeaderWWWAuthenticate = "WWW-Authenticate"
+
+type contextClaimsKey struct{}
+
+// Claims are the claims of a validated access token.
+type Claims struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	Expiry   time.Time
+	// Scopes are read from the space separated "scope" claim or the "scp" array.
+	Scopes []string
+	raw    []byte
+}
+
+// Decode unmarshals all the claims of the token into v, e.g. a struct with json tags.
+func (c *Claims) Decode(v any) error {
+	return json.Unmarshal(c.raw, v)
+}
+
+// HasScopes is true when the token has been granted all the scopes.
+func (c *Claims) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		found := false
+		for _, s := range c.Scopes {
+			if s == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClaimsContext returns a context carrying the claims.
+func NewClaimsContext(parent context.Context, claims *Claims) context.Context {
+	return context.WithValue(parent, contextClaimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by JWTValidator.Authenticate, nil when none.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(contextClaimsKey{}).(*Claims)
+	return claims
+}
+
+// SubjectFromContext returns the subject of the claims of the context, empty when none.
+// It can be used to rate limit by subject: middleware.KeyBySubject(auth.SubjectFromContext)
+func SubjectFromContext(ctx context.Context) string {
+	if claims := ClaimsFromContext(ctx); claims != nil {
+		return claims.Subject
+	}
+	return ""
+}
+
+type validatorConfig struct {
+	jwks  []byte
+	file  string
+	algs  []string
+	realm string
+	now   func() time.Time
+}
+
+// ValidatorOption configures a JWTValidator.
+type ValidatorOption func(*validatorConfig)
+
+// WithJWKS validates the signatures with the static JSON Web Key Set instead of discovering the keys of the issuer.
+func WithJWKS(jwks []byte) ValidatorOption {
+	return func(c *validatorConfig) {
+		c.jwks = jwks
+	}
+}
+
+// WithJWKSFile validates the signatures with the JSON Web Key Set read from the file, see WithJWKS.
+func WithJWKSFile(path string) ValidatorOption {
+	return func(c *validatorConfig) {
+		c.file = path
+	}
+}
+
+// WithSigningAlgs sets the signing algorithms accepted, defaults to RS256.
+func WithSigningAlgs(algs ...string) ValidatorOption {
+	return func(c *validatorConfig) {
+		c.algs = algs
+	}
+}
+
+// WithRealm sets the realm of the WWW-Authenticate challenges.
+func WithRealm(realm string) ValidatorOption {
+	return func(c *validatorConfig) {
+		c.realm = realm
+	}
+}
+
+// WithClock sets the clock used to check the expiry of the tokens.
+func WithClock(now func() time.Time) ValidatorOption {
+	return func(c *validatorConfig) {
+		c.now = now
+	}
+}
+
+// JWTValidator validates the bearer tokens of the incoming requests.
+type JWTValidator struct {
+	verifier *oidc.IDTokenVerifier
+	realm    string
+}
+
+// NewJWTValidator creates a validator of the JWTs issued by the OpenID provider for the audience.
+// The signing keys are discovered from the issuer and fetched from its JWKS endpoint with ctx,
+// which must live as long as the validator, unless WithJWKS or WithJWKSFile are used.
+// An empty audience disables the audience check.
+func NewJWTValidator(ctx context.Context, issuer string, audience string, opts ...ValidatorOption) (*JWTValidator, error) {
+	cfg := &validatorConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	config := &oidc.Config{
+		ClientID:             audience,
+		SkipClientIDCheck:    audience == "",
+		SupportedSigningAlgs: cfg.algs,
+		Now:                  cfg.now,
+	}
+	issuer = strings.TrimSuffix(issuer, "/")
+	if cfg.file != "" {
+		jwks, err := os.ReadFile(cfg.file)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the JWKS file")
+		}
+		cfg.jwks = jwks
+	}
+	v := &JWTValidator{realm: cfg.realm}
+	if cfg.jwks != nil {
+		keySet, err := newStaticKeySet(cfg.jwks)
+		if err != nil {
+			return nil, err
+		}
+		v.verifier = oidc.NewVerifier(issuer, keySet, config)
+		return v, nil
+	}
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to discover the OpenID provider")
+	}
+	v.verifier = provider.Verifier(config)
+	return v, nil
+}
+
+// Validate verifies the signature, the issuer, the audience and the expiry of the token and returns its claims.
+func (v *JWTValidator) Validate(ctx context.Context, token string) (*Claims, error) {
+	idToken, err := v.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims{
+		Issuer:   idToken.Issuer,
+		Subject:  idToken.Subject,
+		Audience: idToken.Audience,
+		Expiry:   idToken.Expiry,
+	}
+	var scopes struct {
+		Scope string          `json:"scope"`
+		SCP   json.RawMessage `json:"scp"`
+	}
+	if err := idToken.Claims(&scopes); err != nil {
+		return nil, err
+	}
+	claims.Scopes = strings.Fields(scopes.Scope)
+	if len(scopes.SCP) > 0 {
+		var scp []string
+		if err := json.Unmarshal(scopes.SCP, &scp); err != nil {
+			var s string
+			if json.Unmarshal(scopes.SCP, &s) != nil {
+				return nil, errors.New("invalid scp claim")
+			}
+			scp = strings.Fields(s)
+		}
+		claims.Scopes = append(claims.Scopes, scp...)
+	}
+	var raw json.RawMessage
+	if err := idToken.Claims(&raw); err != nil {
+		return nil, err
+	}
+	claims.raw = raw
+	return claims, nil
+}
+
+// Authenticate validates the bearer token of the requests and stores its claims in the request context,
+// see ClaimsFromContext. The token must have been granted all the scopes.
+// Failures are answered as defined by RFC 6750 with a WWW-Authenticate challenge:
+// 401 without bearer token or with an invalid token, 400 for a malformed Authorization header
+// and 403 when scopes are missing.
+func (v *JWTValidator) Authenticate(scopes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			values := r.Header.Values("Authorization")
+			scheme, token, _ := strings.Cut(strings.Join(values, ","), " ")
+			if len(values) == 0 || !strings.EqualFold(scheme, "Bearer") {
+				// without bearer token, the challenge carries no error code
+				v.challenge(w, r, http.StatusUnauthorized, "", "", nil)
+				return
+			}
+			if len(values) > 1 || strings.TrimSpace(token) == "" {
+				v.challenge(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "the request must carry one bearer token", nil)
+				return
+			}
+			claims, err := v.Validate(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				v.challenge(w, r, http.StatusUnauthorized, ErrorCodeInvalidToken, "the access token is invalid", nil)
+				return
+			}
+			if !claims.HasScopes(scopes...) {
+				v.challenge(w, r, http.StatusForbidden, ErrorCodeInsufficientScope, "the access token has not been granted the scopes", scopes)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewClaimsContext(r.Context(), claims)))
+		})
+	}
+}
+
+// RequireScopes checks the scopes of the claims stored by Authenticate, e.g. for a single route:
+//
+//	books.Post(validator.RequireScopes("books:write")(http.HandlerFunc(create)).ServeHTTP)
+func (v *JWTValidator) RequireScopes(scopes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := ClaimsFromContext(r.Context())
+			if claims == nil {
+				v.challenge(w, r, http.StatusUnauthorized, "", "", nil)
+				return
+			}
+			if !claims.HasScopes(scopes...) {
+				v.challenge(w, r, http.StatusForbidden, ErrorCodeInsufficientScope, "the access token has not been granted the scopes", scopes)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// challenge answers with the WWW-Authenticate header defined by RFC 6750 and a problem.
+func (v *JWTValidator) challenge(w http.ResponseWriter, r *http.Request, status int, code, description string, scopes []string) {
+	var params []string
+	if v.realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", v.realm))
+	}
+	if code != "" {
+		params = append(params, fmt.Sprintf("error=%q", code), fmt.Sprintf("error_description=%q", description))
+	}
+	if len(scopes) > 0 {
+		params = append(params, fmt.Sprintf("scope=%q", strings.Join(scopes, " ")))
+	}
+	challenge := "Bearer"
+	if len(params) > 0 {
+		challenge += " " + strings.Join(params, ", ")
+	}
+	w.Header().Set(HeaderWWWAuthenticate, challenge)
+	problem := renderer.NewProblem(status, description)
+	if code != "" {
+		problem.With("error", code)
+	}
+	_ = renderer.Problem(w, r, problem)
+}
+
+// staticKeySet verifies the signatures with the keys of a JSON Web Key Set.
+type staticKeySet struct {
+	keys []jose.JSONWebKey
+}
+
+func newStaticKeySet(data []byte) (*staticKeySet, error) {
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, errors.Wrap(err, "invalid JWKS")
+	}
+	return &staticKeySet{keys: jwks.Keys}, nil
+}
+
+func (s *staticKeySet) VerifySignature(_ context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed jwt")
+	}
+	keyID := ""
+	if len(jws.Signatures) > 0 {
+		keyID = jws.Signatures[0].Header.KeyID
+	}
+	for i := range s.keys {
+		if keyID == "" || s.keys[i].KeyID == keyID {
+			if payload, err := jws.Verify(&s.keys[i]); err == nil {
+				return payload, nil
+			}
+		}
+	}
+	return nil, errors.New("failed to verify the signature")
+}
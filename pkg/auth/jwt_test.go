@@ -0,0 +1,219 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/athosone/golib/pkg/auth"
+)
+
+const issuer = "https://login.athosone.com"
+
+// signer signs the tokens with a RSA key published in its JWKS.
+type signer struct {
+	key  *rsa.PrivateKey
+	jwks []byte
+}
+
+func newSigner() *signer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).To(BeNil())
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &key.PublicKey, KeyID: "k1", Algorithm: string(jose.RS256), Use: "sig"},
+	}})
+	Expect(err).To(BeNil())
+	return &signer{key: key, jwks: jwks}
+}
+
+func (s *signer) sign(claims map[string]any) string {
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: s.key, KeyID: "k1"}},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	Expect(err).To(BeNil())
+	payload, err := json.Marshal(claims)
+	Expect(err).To(BeNil())
+	jws, err := sig.Sign(payload)
+	Expect(err).To(BeNil())
+	token, err := jws.CompactSerialize()
+	Expect(err).To(BeNil())
+	return token
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   issuer,
+		"sub":   "alice",
+		"aud":   "books-api",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "books:read books:write",
+		"name":  "Alice",
+	}
+}
+
+var _ = Describe("JWTValidator", func() {
+	var (
+		keys      *signer
+		validator *auth.JWTValidator
+		claims    *auth.Claims
+		handler   http.Handler
+	)
+
+	BeforeEach(func() {
+		if keys == nil {
+			// the generation of RSA keys is slow
+			keys = newSigner()
+		}
+		var err error
+		validator, err = auth.NewJWTValidator(context.Background(), issuer, "books-api",
+			auth.WithJWKS(keys.jwks), auth.WithRealm("books"))
+		Expect(err).To(BeNil())
+		claims = nil
+		handler = validator.Authenticate("books:read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims = auth.ClaimsFromContext(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}))
+	})
+
+	serve := func(authorization ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		for _, value := range authorization {
+			req.Header.Add("Authorization", value)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	It("should store the claims of a valid token", func() {
+		recorder := serve("Bearer " + keys.sign(validClaims()))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(claims).ToNot(BeNil())
+		Expect(claims.Subject).To(Equal("alice"))
+		Expect(claims.Audience).To(ConsistOf("books-api"))
+		Expect(claims.Scopes).To(ConsistOf("books:read", "books:write"))
+		var custom struct {
+			Name string `json:"name"`
+		}
+		Expect(claims.Decode(&custom)).To(Succeed())
+		Expect(custom.Name).To(Equal("Alice"))
+	})
+
+	It("should read the scopes of the scp claim", func() {
+		c := validClaims()
+		delete(c, "scope")
+		c["scp"] = []string{"books:read"}
+		Expect(serve("Bearer " + keys.sign(c)).Code).To(Equal(http.StatusOK))
+		Expect(claims.Scopes).To(ConsistOf("books:read"))
+	})
+
+	It("should challenge the requests without bearer token", func() {
+		for _, recorder := range []*httptest.ResponseRecorder{serve(), serve("Basic dXNlcjpwYXNz")} {
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal(`Bearer realm="books"`))
+		}
+	})
+
+	It("should reject the malformed requests", func() {
+		recorder := serve("Bearer ")
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Header().Get("WWW-Authenticate")).To(ContainSubstring(`error="invalid_request"`))
+
+		token := keys.sign(validClaims())
+		Expect(serve("Bearer "+token, "Bearer "+token).Code).To(Equal(http.StatusBadRequest))
+	})
+
+	DescribeTable("should reject the invalid tokens",
+		func(change func(map[string]any), token func(string) string) {
+			c := validClaims()
+			change(c)
+			recorder := serve("Bearer " + token(keys.sign(c)))
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(
+				HavePrefix(`Bearer realm="books", error="invalid_token", error_description=`))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+			Expect(claims).To(BeNil())
+		},
+		Entry("expired", func(c map[string]any) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, same),
+		Entry("other audience", func(c map[string]any) { c["aud"] = "authors-api" }, same),
+		Entry("other issuer", func(c map[string]any) { c["iss"] = "https://evil.com" }, same),
+		Entry("bad signature", func(map[string]any) {}, func(token string) string {
+			return token[:len(token)-4] + "AAAA"
+		}),
+		Entry("signed by another key", func(map[string]any) {}, func(string) string {
+			return newSigner().sign(validClaims())
+		}),
+	)
+
+	It("should reject the tokens missing scopes", func() {
+		c := validClaims()
+		c["scope"] = "authors:read"
+		recorder := serve("Bearer " + keys.sign(c))
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(recorder.Header().Get("WWW-Authenticate")).To(ContainSubstring(`error="insufficient_scope"`))
+		Expect(recorder.Header().Get("WWW-Authenticate")).To(ContainSubstring(`scope="books:read"`))
+	})
+
+	It("should require scopes per route", func() {
+		handler = validator.Authenticate()(validator.RequireScopes("books:delete")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})))
+		Expect(serve("Bearer " + keys.sign(validClaims())).Code).To(Equal(http.StatusForbidden))
+		c := validClaims()
+		c["scope"] = "books:delete"
+		Expect(serve("Bearer " + keys.sign(c)).Code).To(Equal(http.StatusOK))
+	})
+
+	It("should read the keys of a JWKS file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "jwks.json")
+		Expect(os.WriteFile(path, keys.jwks, 0o600)).To(Succeed())
+		v, err := auth.NewJWTValidator(context.Background(), issuer, "books-api", auth.WithJWKSFile(path))
+		Expect(err).To(BeNil())
+		c, err := v.Validate(context.Background(), keys.sign(validClaims()))
+		Expect(err).To(BeNil())
+		Expect(c.Subject).To(Equal("alice"))
+	})
+
+	It("should discover the keys of the issuer", func() {
+		var sts *httptest.Server
+		sts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/.well-known/openid-configuration":
+				_ = json.NewEncoder(w).Encode(map[string]string{"issuer": sts.URL, "jwks_uri": sts.URL + "/keys"})
+			case "/keys":
+				_, _ = w.Write(keys.jwks)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		DeferCleanup(sts.Close)
+
+		v, err := auth.NewJWTValidator(context.Background(), sts.URL, "books-api")
+		Expect(err).To(BeNil())
+		c := validClaims()
+		c["iss"] = sts.URL
+		claims, err := v.Validate(context.Background(), keys.sign(c))
+		Expect(err).To(BeNil())
+		Expect(claims.Issuer).To(Equal(sts.URL))
+	})
+
+	It("should provide the subject of the context", func() {
+		Expect(auth.SubjectFromContext(context.Background())).To(BeEmpty())
+		ctx := auth.NewClaimsContext(context.Background(), &auth.Claims{Subject: "alice"})
+		Expect(auth.SubjectFromContext(ctx)).To(Equal("alice"))
+	})
+})
+
+func same(token string) string {
+	return token
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/athosone/golib/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+const (
+	attributeClientID  = attribute.Key("oauth2.client_id")
+	attributeExpiresIn = attribute.Key("oauth2.token.expires_in")
+)
+
+// fetchTokenSource fetches a new token on every call, traced when the provider has telemetry.
+type fetchTokenSource struct {
+	auth     *AuthProvider
+	tokenURL string
+	clientID string
+	fetch    func(ctx context.Context) (*oauth2.Token, error)
+	ctx      context.Context
+}
+
+func (s *fetchTokenSource) Token() (*oauth2.Token, error) {
+	if s.auth.telemetry == nil {
+		return s.fetch(s.ctx)
+	}
+	ctx, span := s.auth.telemetry.Tracer.Start(s.ctx, "auth.token",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPURLKey.String(s.tokenURL),
+			attributeClientID.String(s.clientID),
+		),
+	)
+	defer span.End()
+	token, err := s.fetch(ctx)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if !token.Expiry.IsZero() {
+		span.SetAttributes(attributeExpiresIn.Int64(int64(time.Until(token.Expiry).Seconds())))
+	}
+	return token, nil
+}
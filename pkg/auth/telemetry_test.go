@@ -0,0 +1,98 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/athosone/golib/pkg/auth"
+	"github.com/athosone/golib/pkg/telemetry"
+)
+
+// newSTS starts an OpenID provider issuing the token with the given status.
+func newSTS(status *int32, fetches *int32) *httptest.Server {
+	var sts *httptest.Server
+	sts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":         sts.URL,
+				"token_endpoint": sts.URL + "/token",
+				"jwks_uri":       sts.URL + "/keys",
+			})
+		case "/token":
+			atomic.AddInt32(fetches, 1)
+			w.WriteHeader(int(atomic.LoadInt32(status)))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"access_token": "token",
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return sts
+}
+
+var _ = Describe("Telemetry", func() {
+	var (
+		recorder *tracetest.SpanRecorder
+		tp       *sdktrace.TracerProvider
+		sts      *httptest.Server
+		status   int32
+		fetches  int32
+	)
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		tp = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		status, fetches = http.StatusOK, 0
+		sts = newSTS(&status, &fetches)
+		DeferCleanup(sts.Close)
+	})
+
+	It("should trace the token fetches", func() {
+		factory := auth.NewClientCredentialsFactory("client", "secret", sts.URL, "books",
+			auth.WithTelemetry(telemetry.WithTracerProvider(tp)))
+		provider, err := factory(context.Background())
+		Expect(err).To(BeNil())
+
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://books.local", nil)
+			Expect(provider.Authenticate(context.Background(), req)).To(Succeed())
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token"))
+		}
+
+		By("reusing the token until it expires")
+		Expect(atomic.LoadInt32(&fetches)).To(BeEquivalentTo(1))
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("auth.token"))
+		Expect(spans[0].Status().Code).To(Equal(codes.Unset))
+	})
+
+	It("should record the failed fetches", func() {
+		status = http.StatusUnauthorized
+		factory := auth.NewClientCredentialsFactory("client", "secret", sts.URL, "books",
+			auth.WithTelemetry(telemetry.WithTracerProvider(tp)))
+		provider, err := factory(context.Background())
+		Expect(err).To(BeNil())
+
+		req, _ := http.NewRequest(http.MethodGet, "http://books.local", nil)
+		Expect(provider.Authenticate(context.Background(), req)).NotTo(Succeed())
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Status().Code).To(Equal(codes.Error))
+		Expect(spans[0].Events()).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultRefreshBefore is how long before its expiry a token is refreshed.
+	DefaultRefreshBefore = time.Minute
+	// DefaultInitialBackoff is the delay before retrying a failed refresh, doubled on each consecutive failure.
+	DefaultInitialBackoff = time.Second
+	// DefaultMaxBackoff is the maximum delay between the retries of a failed refresh.
+	DefaultMaxBackoff = time.Minute
+)
+
+// TokenRefresh describes a refresh of the token, see WithRefreshHook.
+type TokenRefresh struct {
+	// Token is the new token, nil when the refresh failed.
+	Token *oauth2.Token
+	Err   error
+	// Failures is the number of consecutive failed refreshes, 0 when the refresh succeeded.
+	Failures int
+	Duration time.Duration
+	// Background is true when the refresh was made ahead of the requests, see WithBackgroundRefresh.
+	Background bool
+}
+
+type cacheConfig struct {
+	refreshBefore  time.Duration
+	background     bool
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	hooks          []func(TokenRefresh)
+}
+
+func defaultCacheConfig() cacheConfig {
+	return cacheConfig{
+		refreshBefore:  DefaultRefreshBefore,
+		initialBackoff: DefaultInitialBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+	}
+}
+
+// WithRefreshBefore refreshes the tokens the given duration before they expire, defaults to DefaultRefreshBefore.
+// The duration is capped to half the lifetime of the tokens.
+func WithRefreshBefore(d time.Duration) Option {
+	return func(auth *AuthProvider) {
+		auth.cache.refreshBefore = d
+	}
+}
+
+// WithBackgroundRefresh refreshes the tokens in the background before they expire,
+// so that the requests do not wait for the STS. It stops with the context of the factory.
+func WithBackgroundRefresh() Option {
+	return func(auth *AuthProvider) {
+		auth.cache.background = true
+	}
+}
+
+// WithRefreshBackoff sets the exponential backoff between the retries of a failed refresh,
+// defaults to DefaultInitialBackoff and DefaultMaxBackoff.
+func WithRefreshBackoff(initial, max time.Duration) Option {
+	return func(auth *AuthProvider) {
+		auth.cache.initialBackoff = initial
+		auth.cache.maxBackoff = max
+	}
+}
+
+// WithRefreshHook calls fn after every refresh of the token, e.g. to log or measure them.
+func WithRefreshHook(fn func(TokenRefresh)) Option {
+	return func(auth *AuthProvider) {
+		auth.cache.hooks = append(auth.cache.hooks, fn)
+	}
+}
+
+// cachingTokenSource caches the token of the source until it is about to expire.
+// Concurrent refreshes are deduplicated and failed refreshes are retried with an exponential backoff,
+// meanwhile the cached token is used as long as it has not expired.
+type cachingTokenSource struct {
+	ctx    context.Context
+	source oauth2.TokenSource
+	cfg    cacheConfig
+	group  singleflight.Group
+
+	mu    sync.Mutex
+	token *oauth2.Token
+	// margin is how long before its expiry the token is refreshed, see refreshMargin.
+	margin    time.Duration
+	err       error
+	failures  int
+	retryAt   time.Time
+	scheduled *time.Timer
+}
+
+func newCachingTokenSource(ctx context.Context, source oauth2.TokenSource, cfg cacheConfig) *cachingTokenSource {
+	c := &cachingTokenSource{ctx: ctx, source: source, cfg: cfg}
+	if cfg.background {
+		go func() {
+			<-ctx.Done()
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.scheduled != nil {
+				c.scheduled.Stop()
+			}
+		}()
+	}
+	return c
+}
+
+func (c *cachingTokenSource) Token() (*oauth2.Token, error) {
+	c.mu.Lock()
+	token, margin, err, retryAt := c.token, c.margin, c.err, c.retryAt
+	c.mu.Unlock()
+	now := time.Now()
+	if fresh(token, margin, now) {
+		return token, nil
+	}
+	if err != nil && now.Before(retryAt) {
+		// backing off, the STS is not called again
+		if valid(token, now) {
+			return token, nil
+		}
+		return nil, err
+	}
+	token, err = c.refresh(false)
+	if err != nil {
+		if cached := c.cached(); valid(cached, time.Now()) {
+			return cached, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// replace returns a new token for the token rejected by a server, unless another caller already replaced it.
+func (c *cachingTokenSource) replace(rejected *oauth2.Token) (*oauth2.Token, error) {
+	c.mu.Lock()
+	current, err, retryAt := c.token, c.err, c.retryAt
+	c.mu.Unlock()
+	now := time.Now()
+	if current != nil && current.AccessToken != rejected.AccessToken && valid(current, now) {
+		return current, nil
+	}
+	if err != nil && now.Before(retryAt) {
+		return nil, err
+	}
+	return c.refresh(false)
+}
+
+// refresh fetches a new token, once for all the concurrent callers.
+func (c *cachingTokenSource) refresh(background bool) (*oauth2.Token, error) {
+	v, err, _ := c.group.Do("token", func() (any, error) {
+		start := time.Now()
+		token, err := c.source.Token()
+		event := TokenRefresh{Token: token, Err: err, Duration: time.Since(start), Background: background}
+
+		c.mu.Lock()
+		if err != nil {
+			backoff := c.cfg.initialBackoff << c.failures
+			if backoff > c.cfg.maxBackoff || backoff <= 0 {
+				backoff = c.cfg.maxBackoff
+			}
+			c.err, c.retryAt = err, time.Now().Add(backoff)
+			c.failures++
+			event.Token, event.Failures = nil, c.failures
+			if c.cfg.background && valid(c.token, time.Now()) {
+				c.schedule(backoff)
+			}
+		} else {
+			c.token, c.err, c.failures = token, nil, 0
+			c.margin = c.refreshMargin(token, start)
+			if delay := time.Until(token.Expiry) - c.margin; c.cfg.background && !token.Expiry.IsZero() && delay > 0 {
+				c.schedule(delay)
+			}
+		}
+		c.mu.Unlock()
+
+		for _, hook := range c.cfg.hooks {
+			hook(event)
+		}
+		return token, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*oauth2.Token), nil
+}
+
+// schedule refreshes the token in the background after d, c.mu must be held.
+func (c *cachingTokenSource) schedule(d time.Duration) {
+	if c.ctx.Err() != nil {
+		return
+	}
+	if c.scheduled != nil {
+		c.scheduled.Stop()
+	}
+	c.scheduled = time.AfterFunc(d, func() {
+		if c.ctx.Err() == nil {
+			_, _ = c.refresh(true)
+		}
+	})
+}
+
+func (c *cachingTokenSource) cached() *oauth2.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// refreshMargin returns how long before its expiry the token is refreshed: the refreshBefore option,
+// capped to half the lifetime of the token so that short-lived tokens are still cached.
+func (c *cachingTokenSource) refreshMargin(token *oauth2.Token, issued time.Time) time.Duration {
+	margin := c.cfg.refreshBefore
+	if lifetime := token.Expiry.Sub(issued); margin > lifetime/2 {
+		margin = lifetime / 2
+	}
+	if margin < 0 {
+		return 0
+	}
+	return margin
+}
+
+// fresh is true when the token does not need to be refreshed yet.
+func fresh(token *oauth2.Token, margin time.Duration, now time.Time) bool {
+	return token != nil && (token.Expiry.IsZero() || now.Before(token.Expiry.Add(-margin)))
+}
+
+// valid is true when the token has not expired.
+func valid(token *oauth2.Token, now time.Time) bool {
+	return token != nil && token.AccessToken != "" && (token.Expiry.IsZero() || now.Before(token.Expiry))
+}
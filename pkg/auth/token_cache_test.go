@@ -0,0 +1,193 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/auth"
+)
+
+// tokenServer is an OpenID provider issuing the tokens token-1, token-2... expiring in expiresIn seconds.
+type tokenServer struct {
+	*httptest.Server
+	status    int32
+	fetches   int32
+	expiresIn int
+	delay     time.Duration
+}
+
+func newTokenServer(expiresIn int, delay time.Duration) *tokenServer {
+	s := &tokenServer{status: http.StatusOK, expiresIn: expiresIn, delay: delay}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": s.URL, "token_endpoint": s.URL + "/token"})
+		case "/token":
+			time.Sleep(s.delay)
+			n := atomic.AddInt32(&s.fetches, 1)
+			w.WriteHeader(int(atomic.LoadInt32(&s.status)))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"access_token": fmt.Sprintf("token-%d", n),
+				"token_type":   "Bearer",
+				"expires_in":   s.expiresIn,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return s
+}
+
+func (s *tokenServer) Fetches() int32 {
+	return atomic.LoadInt32(&s.fetches)
+}
+
+var _ = Describe("Token cache", func() {
+	var (
+		mu     sync.Mutex
+		events []auth.TokenRefresh
+	)
+	hook := auth.WithRefreshHook(func(e auth.TokenRefresh) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+	refreshes := func() []auth.TokenRefresh {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]auth.TokenRefresh(nil), events...)
+	}
+
+	BeforeEach(func() {
+		events = nil
+	})
+
+	newProvider := func(ctx context.Context, sts *tokenServer, opts ...auth.Option) *auth.AuthProvider {
+		provider, err := auth.NewClientCredentialsFactory("client", "secret", sts.URL, "books", append(opts, hook)...)(ctx)
+		Expect(err).To(BeNil())
+		return provider
+	}
+
+	authenticate := func(provider *auth.AuthProvider) (string, error) {
+		req, _ := http.NewRequest(http.MethodGet, "http://books.local", nil)
+		err := provider.Authenticate(context.Background(), req)
+		return req.Header.Get("Authorization"), err
+	}
+
+	It("should fetch the token once for concurrent requests", func() {
+		sts := newTokenServer(3600, 50*time.Millisecond)
+		DeferCleanup(sts.Close)
+		provider := newProvider(context.Background(), sts)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				header, err := authenticate(provider)
+				Expect(err).To(BeNil())
+				Expect(header).To(Equal("Bearer token-1"))
+			}()
+		}
+		wg.Wait()
+		Expect(sts.Fetches()).To(BeEquivalentTo(1))
+		Expect(refreshes()).To(HaveLen(1))
+		Expect(refreshes()[0].Token.AccessToken).To(Equal("token-1"))
+	})
+
+	It("should refresh the token before it expires", func() {
+		sts := newTokenServer(1, 0)
+		DeferCleanup(sts.Close)
+		// the refresh margin is capped to half the lifetime of the token
+		provider := newProvider(context.Background(), sts, auth.WithRefreshBefore(time.Hour))
+
+		Expect(authenticate(provider)).To(Equal("Bearer token-1"))
+		Expect(authenticate(provider)).To(Equal("Bearer token-1"))
+		time.Sleep(550 * time.Millisecond)
+		Expect(authenticate(provider)).To(Equal("Bearer token-2"))
+		Expect(sts.Fetches()).To(BeEquivalentTo(2))
+	})
+
+	It("should back off after a failed refresh", func() {
+		sts := newTokenServer(3600, 0)
+		DeferCleanup(sts.Close)
+		atomic.StoreInt32(&sts.status, http.StatusServiceUnavailable)
+		provider := newProvider(context.Background(), sts, auth.WithRefreshBackoff(100*time.Millisecond, time.Second))
+
+		_, err := authenticate(provider)
+		Expect(err).ToNot(BeNil())
+		// the client credentials flow tries both authentication styles
+		fetches := sts.Fetches()
+		_, err = authenticate(provider)
+		Expect(err).ToNot(BeNil())
+		Expect(sts.Fetches()).To(Equal(fetches))
+
+		atomic.StoreInt32(&sts.status, http.StatusOK)
+		time.Sleep(110 * time.Millisecond)
+		Expect(authenticate(provider)).To(Equal(fmt.Sprintf("Bearer token-%d", fetches+1)))
+
+		events := refreshes()
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Err).ToNot(BeNil())
+		Expect(events[0].Failures).To(Equal(1))
+		Expect(events[1].Err).To(BeNil())
+		Expect(events[1].Failures).To(Equal(0))
+	})
+
+	It("should use the cached token while the STS fails", func() {
+		sts := newTokenServer(1, 0)
+		DeferCleanup(sts.Close)
+		provider := newProvider(context.Background(), sts)
+
+		Expect(authenticate(provider)).To(Equal("Bearer token-1"))
+		atomic.StoreInt32(&sts.status, http.StatusInternalServerError)
+		time.Sleep(550 * time.Millisecond)
+		Expect(authenticate(provider)).To(Equal("Bearer token-1"))
+		Expect(sts.Fetches()).To(BeNumerically(">", 1))
+		Expect(refreshes()[1].Err).ToNot(BeNil())
+	})
+
+	It("should cache the tokens living less than the refresh margin", func() {
+		sts := newTokenServer(60, 0)
+		DeferCleanup(sts.Close)
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		provider := newProvider(ctx, sts, auth.WithRefreshBefore(2*time.Minute), auth.WithBackgroundRefresh())
+
+		for i := 0; i < 20; i++ {
+			Expect(authenticate(provider)).To(Equal("Bearer token-1"))
+		}
+		Consistently(sts.Fetches, 200*time.Millisecond).Should(BeEquivalentTo(1))
+	})
+
+	It("should refresh the token in the background", func() {
+		sts := newTokenServer(1, 0)
+		DeferCleanup(sts.Close)
+		ctx, cancel := context.WithCancel(context.Background())
+		provider := newProvider(ctx, sts, auth.WithBackgroundRefresh())
+
+		Expect(authenticate(provider)).To(Equal("Bearer token-1"))
+		Eventually(sts.Fetches).Should(BeEquivalentTo(2))
+		Eventually(func() bool {
+			events := refreshes()
+			return len(events) >= 2 && events[1].Background
+		}).Should(BeTrue())
+		Expect(authenticate(provider)).To(Equal("Bearer token-2"))
+
+		By("stopping with the context")
+		cancel()
+		fetches := sts.Fetches()
+		Consistently(sts.Fetches, 300*time.Millisecond).Should(BeNumerically("<=", fetches+1))
+	})
+})
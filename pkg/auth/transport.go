@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultClientTimeout is the timeout of the requests of the clients created by NewHTTPClient.
+const DefaultClientTimeout = 30 * time.Second
+
+// Transport is a http.RoundTripper authenticating the requests with the AuthProvider.
+// When the server answers 401, the token is refreshed and the request is sent again once,
+// provided its body can be replayed (see http.Request.GetBody).
+type Transport struct {
+	Provider *AuthProvider
+	// Base sends the requests, defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Provider.tokenSource.Token()
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, errors.Wrap(err, "Could not get an access token")
+	}
+	authorized := req.Clone(req.Context())
+	token.SetAuthHeader(authorized)
+	resp, err := t.base().RoundTrip(authorized)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	cache, ok := t.Provider.tokenSource.(*cachingTokenSource)
+	if !ok || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return resp, nil
+	}
+	fresh, err := cache.replace(token)
+	if err != nil {
+		// the 401 is more useful to the caller than the failure of the refresh
+		return resp, nil
+	}
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	fresh.SetAuthHeader(retry)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return t.base().RoundTrip(retry)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+type clientConfig struct {
+	timeout   time.Duration
+	transport http.RoundTripper
+}
+
+// ClientOption configures the client created by NewHTTPClient.
+type ClientOption func(*clientConfig)
+
+// WithClientTimeout sets the timeout of the requests, including the reading of the response body.
+// It defaults to DefaultClientTimeout; 0 disables it.
+func WithClientTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.timeout = timeout
+	}
+}
+
+// WithClientTransport sets the transport sending the authenticated requests, defaults to http.DefaultTransport.
+func WithClientTransport(transport http.RoundTripper) ClientOption {
+	return func(c *clientConfig) {
+		c.transport = transport
+	}
+}
+
+// NewHTTPClient creates a http.Client authenticating its requests with the provider created by the factory,
+// see Transport.
+func NewHTTPClient(ctx context.Context, factory AuthProviderFactory, opts ...ClientOption) (*http.Client, error) {
+	cfg := &clientConfig{timeout: DefaultClientTimeout}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	provider, err := factory(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not create the auth provider")
+	}
+	return &http.Client{
+		Transport: &Transport{Provider: provider, Base: cfg.transport},
+		Timeout:   cfg.timeout,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/auth"
+)
+
+// apiServer accepts the requests carrying the accepted token and echoes their body.
+type apiServer struct {
+	*httptest.Server
+	accepted atomic.Value
+	hits     int32
+	delay    time.Duration
+}
+
+func newAPIServer(accepted string, delay time.Duration) *apiServer {
+	s := &apiServer{delay: delay}
+	s.accepted.Store(accepted)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.hits, 1)
+		time.Sleep(s.delay)
+		if r.Header.Get("Authorization") != "Bearer "+s.accepted.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.Copy(w, r.Body)
+	}))
+	return s
+}
+
+func (s *apiServer) Hits() int32 {
+	return atomic.LoadInt32(&s.hits)
+}
+
+var _ = Describe("HTTP client", func() {
+	var (
+		sts *tokenServer
+		api *apiServer
+	)
+
+	newClient := func(opts ...auth.ClientOption) *http.Client {
+		factory := auth.NewClientCredentialsFactory("client", "secret", sts.URL, "books")
+		client, err := auth.NewHTTPClient(context.Background(), factory, opts...)
+		Expect(err).To(BeNil())
+		return client
+	}
+
+	BeforeEach(func() {
+		sts = newTokenServer(3600, 0)
+		DeferCleanup(sts.Close)
+	})
+
+	It("should authenticate the requests", func() {
+		api = newAPIServer("token-1", 0)
+		DeferCleanup(api.Close)
+		client := newClient()
+
+		for i := 0; i < 2; i++ {
+			resp, err := client.Post(api.URL, "text/plain", strings.NewReader("hello"))
+			Expect(err).To(BeNil())
+			body, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(string(body)).To(Equal("hello"))
+		}
+		Expect(sts.Fetches()).To(BeEquivalentTo(1))
+	})
+
+	It("should not modify the request", func() {
+		api = newAPIServer("token-1", 0)
+		DeferCleanup(api.Close)
+		req, _ := http.NewRequest(http.MethodGet, api.URL, nil)
+		resp, err := newClient().Do(req)
+		Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+		Expect(req.Header.Get("Authorization")).To(BeEmpty())
+	})
+
+	It("should retry once with a new token when the token is rejected", func() {
+		api = newAPIServer("token-2", 0)
+		DeferCleanup(api.Close)
+		client := newClient()
+
+		resp, err := client.Post(api.URL, "text/plain", strings.NewReader("hello"))
+		Expect(err).To(BeNil())
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(string(body)).To(Equal("hello"))
+		Expect(sts.Fetches()).To(BeEquivalentTo(2))
+		Expect(api.Hits()).To(BeEquivalentTo(2))
+
+		By("keeping the new token")
+		resp, err = client.Get(api.URL)
+		Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(sts.Fetches()).To(BeEquivalentTo(2))
+	})
+
+	It("should return the 401 when the new token is rejected too", func() {
+		api = newAPIServer("none", 0)
+		DeferCleanup(api.Close)
+
+		resp, err := newClient().Get(api.URL)
+		Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(api.Hits()).To(BeEquivalentTo(2))
+		Expect(sts.Fetches()).To(BeEquivalentTo(2))
+	})
+
+	It("should not retry the requests whose body cannot be replayed", func() {
+		api = newAPIServer("token-2", 0)
+		DeferCleanup(api.Close)
+		req, _ := http.NewRequest(http.MethodPost, api.URL, io.NopCloser(bytes.NewBufferString("hello")))
+
+		resp, err := newClient().Do(req)
+		Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(api.Hits()).To(BeEquivalentTo(1))
+	})
+
+	It("should fail when the token cannot be fetched", func() {
+		api = newAPIServer("token-1", 0)
+		DeferCleanup(api.Close)
+		atomic.StoreInt32(&sts.status, http.StatusServiceUnavailable)
+
+		_, err := newClient().Get(api.URL)
+		Expect(err).ToNot(BeNil())
+		Expect(api.Hits()).To(BeEquivalentTo(0))
+	})
+
+	It("should time out", func() {
+		api = newAPIServer("token-1", 200*time.Millisecond)
+		DeferCleanup(api.Close)
+
+		_, err := newClient(auth.WithClientTimeout(50 * time.Millisecond)).Get(api.URL)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Timeout"))
+	})
+
+	It("should send the requests with the given transport", func() {
+		api = newAPIServer("token-1", 0)
+		DeferCleanup(api.Close)
+		var sent int32
+		transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&sent, 1)
+			return http.DefaultTransport.RoundTrip(req)
+		})
+
+		resp, err := newClient(auth.WithClientTransport(transport)).Get(api.URL)
+		Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(atomic.LoadInt32(&sent)).To(BeEquivalentTo(1))
+	})
+})
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
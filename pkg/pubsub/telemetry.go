@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"context"
+
+	"github.com/athosone/golib/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+)
+
+const attributeTopic = attribute.Key("pubsub.topic")
+
+type topicConfig struct {
+	metrics *topicMetrics
+}
+
+// TopicOption configures a Topic.
+type TopicOption func(*topicConfig)
+
+// WithTelemetry records the metrics of the topic with OpenTelemetry, see telemetry.New for the providers:
+//   - pubsub.subscribers: number of subscribers
+//   - pubsub.deliveries.in_flight: number of events waiting to be received by a subscriber
+//   - pubsub.published: number of published events
+//   - pubsub.delivered: number of events received by subscribers
+//   - pubsub.dropped: number of events not received before the topic was closed
+//
+// Measures are attributed with the name of the topic.
+func WithTelemetry(name string, opts ...telemetry.Option) TopicOption {
+	return func(c *topicConfig) {
+		t := telemetry.New(opts...)
+		c.metrics = &topicMetrics{
+			attrs:       []attribute.KeyValue{attributeTopic.String(name)},
+			subscribers: t.Int64UpDownCounter("pubsub.subscribers", "Number of subscribers of the topic", unit.Dimensionless),
+			inFlight:    t.Int64UpDownCounter("pubsub.deliveries.in_flight", "Number of events waiting to be received", unit.Dimensionless),
+			published:   t.Int64Counter("pubsub.published", "Number of events published to the topic", unit.Dimensionless),
+			delivered:   t.Int64Counter("pubsub.delivered", "Number of events received by subscribers", unit.Dimensionless),
+			dropped:     t.Int64Counter("pubsub.dropped", "Number of events dropped when the topic was closed", unit.Dimensionless),
+		}
+	}
+}
+
+// topicMetrics records the metrics of a topic, a nil *topicMetrics records nothing.
+type topicMetrics struct {
+	attrs       []attribute.KeyValue
+	subscribers syncint64.UpDownCounter
+	inFlight    syncint64.UpDownCounter
+	published   syncint64.Counter
+	delivered   syncint64.Counter
+	dropped     syncint64.Counter
+}
+
+func (m *topicMetrics) subscribed(n int64) {
+	if m != nil {
+		m.subscribers.Add(context.Background(), n, m.attrs...)
+	}
+}
+
+func (m *topicMetrics) publish() {
+	if m != nil {
+		m.published.Add(context.Background(), 1, m.attrs...)
+	}
+}
+
+func (m *topicMetrics) deliveryStarted() {
+	if m != nil {
+		m.inFlight.Add(context.Background(), 1, m.attrs...)
+	}
+}
+
+func (m *topicMetrics) deliveryEnded(delivered bool) {
+	if m == nil {
+		return
+	}
+	ctx := context.Background()
+	m.inFlight.Add(ctx, -1, m.attrs...)
+	if delivered {
+		m.delivered.Add(ctx, 1, m.attrs...)
+	} else {
+		m.dropped.Add(ctx, 1, m.attrs...)
+	}
+}
@@ -0,0 +1,65 @@
+package pubsub
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/athosone/golib/pkg/telemetry"
+)
+
+var _ = Describe("Topic telemetry", func() {
+	var (
+		reader sdkmetric.Reader
+		topic  *Topic[testEvent]
+	)
+
+	// value returns the sum of the metric for the topic
+	value := func(name string) int64 {
+		rm, err := reader.Collect(context.Background())
+		Expect(err).To(BeNil())
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != name {
+					continue
+				}
+				var total int64
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					Expect(dp.Attributes.HasValue(attribute.Key("pubsub.topic"))).To(BeTrue())
+					total += dp.Value
+				}
+				return total
+			}
+		}
+		return 0
+	}
+
+	BeforeEach(func() {
+		reader = sdkmetric.NewManualReader()
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		topic = NewTopic[testEvent](context.TODO(), WithTelemetry("books", telemetry.WithMeterProvider(mp)))
+	})
+
+	It("records the subscribers and the deliveries", func() {
+		rec1 := topic.Subscribe()
+		rec2 := topic.Subscribe()
+		Expect(value("pubsub.subscribers")).To(BeEquivalentTo(2))
+
+		topic.Publish(testEvent{})
+		Expect(value("pubsub.published")).To(BeEquivalentTo(1))
+		Eventually(rec1).Should(Receive())
+		Eventually(func() int64 { return value("pubsub.delivered") }).Should(BeEquivalentTo(1))
+		Expect(value("pubsub.deliveries.in_flight")).To(BeEquivalentTo(1))
+
+		By("closing the topic before the second subscriber receives the event")
+		topic.Close()
+		Eventually(rec2).Should(BeClosed())
+		Expect(value("pubsub.deliveries.in_flight")).To(BeEquivalentTo(0))
+		Expect(value("pubsub.dropped")).To(BeEquivalentTo(1))
+		Expect(value("pubsub.subscribers")).To(BeEquivalentTo(0))
+	})
+})
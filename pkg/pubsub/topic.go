@@ -17,15 +17,21 @@ type Topic[T any] struct {
 	ctx      context.Context
 	wg       sync.WaitGroup
 	isClosed bool
+	metrics  *topicMetrics
 }
 
-func NewTopic[T any](parentContext context.Context) *Topic[T] {
+func NewTopic[T any](parentContext context.Context, opts ...TopicOption) *Topic[T] {
 	ctx, cancel := context.WithCancel(parentContext)
+	cfg := &topicConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
 
 	return &Topic[T]{
-		events: []chan<- T{},
-		ctx:    ctx,
-		cancel: cancel,
+		events:  []chan<- T{},
+		ctx:     ctx,
+		cancel:  cancel,
+		metrics: cfg.metrics,
 	}
 }
 
@@ -40,6 +46,7 @@ func (o *Topic[T]) Subscribe() <-chan T {
 	}
 	ch := make(chan T)
 	o.events = append(o.events, ch)
+	o.metrics.subscribed(1)
 	return ch
 }
 
@@ -49,14 +56,18 @@ func (o *Topic[T]) Publish(evt T) {
 	if o.isClosed || len(o.events) == 0 {
 		return
 	}
+	o.metrics.publish()
 
 	for _, c := range o.events {
 		o.wg.Add(1)
+		o.metrics.deliveryStarted()
 		go func(c chan<- T) {
 			defer o.wg.Done()
 			select {
 			case c <- evt:
+				o.metrics.deliveryEnded(true)
 			case <-o.ctx.Done():
+				o.metrics.deliveryEnded(false)
 			}
 		}(c)
 	}
@@ -74,6 +85,7 @@ func (o *Topic[T]) Close() {
 	for _, c := range o.events {
 		close(c)
 	}
+	o.metrics.subscribed(-int64(len(o.events)))
 }
 
 func (o *Topic[T]) IsClosed() bool {
@@ -40,7 +40,11 @@ var _ = Describe("Queue events", func() {
 				go func(r <-chan testEvent) {
 					for {
 						select {
-						case v := <-rec:
+						case v, ok := <-rec:
+							if !ok {
+								// the channel is closed with the queue
+								return
+							}
 							Expect(v).ToNot(BeNil())
 						case <-ctx.Done():
 							return
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"errors"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AcceptList holds the media ranges of an Accept (or Content-Type) header ordered by preference:
+// quality first, then specificity (text/html;level=1 > text/html > text/* > */*), then ranges with an explicit
+// q parameter, then header order.
+// Ranges with q=0 are kept to exclude the media types they match, see Quality.
+type AcceptList []ContentMediaType
+
+// ParseAccept parses the header value as specified by RFC 9110 section 12.5.1: https://www.rfc-editor.org/rfc/rfc9110#section-12.5.1
+// Commas and semicolons inside quoted strings are preserved, parameters following q are accept extensions and are ignored.
+// Invalid ranges are skipped, an error is returned when the value holds no valid range.
+// A blank value returns an empty list.
+func ParseAccept(headerValue string) (AcceptList, error) {
+	if strings.TrimSpace(headerValue) == "" {
+		return nil, nil
+	}
+	var list AcceptList
+	for _, media := range splitQuoted(headerValue, ',') {
+		mt, ok := parseMediaRange(media)
+		if ok {
+			list = append(list, mt)
+		}
+	}
+	if len(list) == 0 {
+		return nil, errors.New("no supported content type found")
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].prefer(list[j])
+	})
+	return list, nil
+}
+
+func parseMediaRange(media string) (ContentMediaType, bool) {
+	parts := splitQuoted(media, ';')
+	mediaRange := strings.TrimSpace(parts[0])
+	quality, isQualitySet := 1.0, false
+	for i, p := range parts[1:] {
+		key, value, _ := strings.Cut(p, "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		q, err := parseQuality(strings.TrimSpace(value))
+		if err != nil {
+			return ContentMediaType{}, false
+		}
+		quality, isQualitySet = q, true
+		parts = parts[:i+1]
+		break
+	}
+	if len(parts) > 1 {
+		mediaRange += ";" + strings.Join(parts[1:], ";")
+	}
+	mediaType, params, err := mime.ParseMediaType(mediaRange)
+	if err != nil {
+		return ContentMediaType{}, false
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+	format := "*"
+	if _, subtype, ok := strings.Cut(mediaType, "/"); ok {
+		format = subtype
+	}
+	if strings.Contains(format, "++") || strings.HasPrefix(format, "+") || strings.HasSuffix(format, "+") {
+		// empty structured syntax suffix
+		return ContentMediaType{}, false
+	}
+	if i := strings.LastIndexByte(format, '+'); i >= 0 {
+		format = format[i+1:]
+	}
+	return ContentMediaType{
+		FullyQualifiedType: mime.FormatMediaType(mediaType, params),
+		Type:               mediaType,
+		Format:             format,
+		Quality:            quality,
+		IsQualitySet:       isQualitySet,
+		IsAny:              mediaType == "*" || mediaType == "*/*",
+		Params:             params,
+	}, true
+}
+
+// parseQuality parses a qvalue: a number between 0 and 1 with at most three decimals.
+func parseQuality(value string) (float64, error) {
+	if len(value) == 0 || len(value) > 5 || (value[0] != '0' && value[0] != '1') {
+		return 0, errors.New("invalid quality")
+	}
+	q, err := strconv.ParseFloat(value, 64)
+	if err != nil || q < 0 || q > 1 {
+		return 0, errors.New("invalid quality")
+	}
+	return q, nil
+}
+
+// splitQuoted splits s around sep, ignoring the separators inside quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts   []string
+		start   int
+		quoted  bool
+		escaped bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// Specificity ranks the media range: 0 for */*, 1 for type/*, 2 for type/subtype plus the number of parameters.
+func (m ContentMediaType) Specificity() int {
+	switch {
+	case m.IsAny:
+		return 0
+	case strings.HasSuffix(m.Type, "/*"):
+		return 1
+	default:
+		return 2 + len(m.Params)
+	}
+}
+
+// prefer is true when m is preferred over o.
+func (m ContentMediaType) prefer(o ContentMediaType) bool {
+	if m.Quality != o.Quality {
+		return m.Quality > o.Quality
+	}
+	if sm, so := m.Specificity(), o.Specificity(); sm != so {
+		return sm > so
+	}
+	return m.IsQualitySet && !o.IsQualitySet
+}
+
+// Matches is true when the media range matches the media type: the type matches the range
+// (wildcards included) and every parameter of the range is carried by the media type with the same value.
+func (m ContentMediaType) Matches(mediaType string, params map[string]string) bool {
+	mediaType = strings.ToLower(mediaType)
+	switch {
+	case m.IsAny:
+	case strings.HasSuffix(m.Type, "/*"):
+		if !strings.HasPrefix(mediaType, strings.TrimSuffix(m.Type, "*")) {
+			return false
+		}
+	case m.Type != mediaType:
+		return false
+	}
+	for k, v := range m.Params {
+		pv, ok := params[k]
+		if !ok || (pv != v && !(k == "charset" && strings.EqualFold(pv, v))) {
+			return false
+		}
+	}
+	return true
+}
+
+// Quality returns the quality of the media type: the quality of the most specific range matching it,
+// 0 when no range matches. An empty list accepts everything.
+func (l AcceptList) Quality(mediaType string) float64 {
+	if len(l) == 0 {
+		return 1
+	}
+	t, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return 0
+	}
+	return l.quality(t, params)
+}
+
+func (l AcceptList) quality(mediaType string, params map[string]string) float64 {
+	best, quality := -1, 0.0
+	for _, m := range l {
+		if s := m.Specificity(); s > best && m.Matches(mediaType, params) {
+			best, quality = s, m.Quality
+		}
+	}
+	return quality
+}
+
+// Negotiate returns the offer with the highest quality, the first one on ties.
+// It returns false when every offer is excluded or not matched by the list.
+func (l AcceptList) Negotiate(offers ...string) (string, bool) {
+	selected, bestQuality := "", 0.0
+	for _, offer := range offers {
+		if q := l.Quality(offer); q > bestQuality {
+			selected, bestQuality = offer, q
+		}
+	}
+	return selected, bestQuality > 0
+}
+
+// Negotiate returns the offer preferred by the Accept header value, see AcceptList.Negotiate.
+func Negotiate(accept string, offers ...string) (string, bool) {
+	list, err := ParseAccept(accept)
+	if err != nil {
+		return "", false
+	}
+	return list.Negotiate(offers...)
+}
@@ -0,0 +1,88 @@
+package server_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server"
+)
+
+var _ = Describe("ParseAccept", func() {
+	types := func(list server.AcceptList) []string {
+		var result []string
+		for _, mt := range list {
+			result = append(result, mt.FullyQualifiedType)
+		}
+		return result
+	}
+
+	It("should order ranges by quality then specificity", func() {
+		list, err := server.ParseAccept("*/*, text/*, text/html, text/html;level=1, application/json;q=0.5")
+		Expect(err).To(BeNil())
+		Expect(types(list)).To(Equal([]string{"text/html; level=1", "text/html", "text/*", "*/*", "application/json"}))
+	})
+	It("should keep the header order on ties", func() {
+		list, err := server.ParseAccept("application/yaml, application/xml")
+		Expect(err).To(BeNil())
+		Expect(types(list)).To(Equal([]string{"application/yaml", "application/xml"}))
+	})
+	It("should not split quoted strings", func() {
+		list, err := server.ParseAccept(`text/plain; foo="a,b;c", text/html`)
+		Expect(err).To(BeNil())
+		Expect(list).To(HaveLen(2))
+		Expect(list[0].Params).To(Equal(map[string]string{"foo": "a,b;c"}))
+	})
+	It("should ignore accept extensions", func() {
+		list, err := server.ParseAccept("text/html; level=1; q=0.5; ext=1")
+		Expect(err).To(BeNil())
+		Expect(list[0].Quality).To(Equal(0.5))
+		Expect(list[0].Params).To(Equal(map[string]string{"level": "1"}))
+	})
+	It("should use the last structured suffix as format", func() {
+		list, err := server.ParseAccept("application/vnd.athosone.book+ld+json")
+		Expect(err).To(BeNil())
+		Expect(list[0].Format).To(Equal("json"))
+	})
+	It("should skip invalid qualities", func() {
+		list, err := server.ParseAccept("text/html;q=2, text/plain;q=0.1234, application/json")
+		Expect(err).To(BeNil())
+		Expect(types(list)).To(Equal([]string{"application/json"}))
+	})
+	It("should fail without valid range", func() {
+		_, err := server.ParseAccept("text/html;q=abc")
+		Expect(err).NotTo(BeNil())
+	})
+	It("should not return excluded types from ParseMediaType", func() {
+		mh, err := server.ParseMediaType("text/html;q=0, */*")
+		Expect(err).To(BeNil())
+		Expect(mh).To(HaveLen(1))
+		Expect(mh[0].IsAny).To(BeTrue())
+	})
+})
+
+var _ = Describe("Negotiate", func() {
+	It("should use the quality of the most specific range", func() {
+		offer, ok := server.Negotiate("text/*;q=0.3, text/html;q=0.7, text/html;level=1, */*;q=0.5",
+			"text/plain", "text/html", "image/jpeg", "text/html; level=1")
+		Expect(ok).To(BeTrue())
+		Expect(offer).To(Equal("text/html; level=1"))
+	})
+	It("should exclude ranges with q=0", func() {
+		offer, ok := server.Negotiate("text/html;q=0, */*", "text/html", "application/json")
+		Expect(ok).To(BeTrue())
+		Expect(offer).To(Equal("application/json"))
+
+		_, ok = server.Negotiate("text/html;q=0", "text/html")
+		Expect(ok).To(BeFalse())
+	})
+	It("should match parameters", func() {
+		offer, ok := server.Negotiate("application/vnd.athosone.book+json; v=v2", "application/vnd.athosone.book+json; v=v1", "application/vnd.athosone.book+json; v=v2")
+		Expect(ok).To(BeTrue())
+		Expect(offer).To(Equal("application/vnd.athosone.book+json; v=v2"))
+	})
+	It("should select the first offer without Accept header", func() {
+		offer, ok := server.Negotiate("", "application/json", "application/xml")
+		Expect(ok).To(BeTrue())
+		Expect(offer).To(Equal("application/json"))
+	})
+})
@@ -1,13 +1,22 @@
 package middleware
 
 import (
+	"bufio"
 	"compress/flate"
 	"compress/gzip"
-	"container/heap"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
 	"net/http"
-	"strconv"
+	"path"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/andybalholm/brotli"
+	"github.com/athosone/golib/pkg/server/negotiation"
+	"github.com/klauspost/compress/zstd"
 	"github.com/pkg/errors"
 )
 
@@ -15,122 +24,467 @@ import (
 
 const defaultEncoding = "gzip"
 
-var (
-	supportedEncodings = map[string]compressFunc{
-		"*":       compressWithGzip,
-		"gzip":    compressWithGzip,
-		"deflate": compressWithDeflate,
+// DefaultCompressMinSize is the size under which responses are not compressed.
+const DefaultCompressMinSize = 1024
+
+// DefaultCompressContentTypes are the media types compressed by default.
+var DefaultCompressContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/xml",
+	"application/yaml",
+	"application/x-yaml",
+	"application/javascript",
+	"application/x-ndjson",
+	"image/svg+xml",
+	"*+json",
+	"*+xml",
+	"*+yaml",
+}
+
+// encoder is implemented by the writers of the compression algorithms.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressor holds the pool of encoders of an encoding.
+type compressor struct {
+	pool sync.Pool
+}
+
+func newCompressor(create func() encoder) *compressor {
+	return &compressor{pool: sync.Pool{New: func() any { return create() }}}
+}
+
+func (c *compressor) get(w io.Writer) encoder {
+	enc := c.pool.Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+func (c *compressor) put(enc encoder) {
+	enc.Reset(io.Discard)
+	c.pool.Put(enc)
+}
+
+// DefaultCompressPreference is the order in which the encodings are chosen when the client accepts them with the same quality.
+var DefaultCompressPreference = []string{"br", "zstd", "gzip", "deflate"}
+
+// contentEncoding creates the encoders and the decoders of an encoding.
+type contentEncoding struct {
+	// encoder creates an encoder at the given level.
+	encoder func(level int) encoder
+	decoder func(r io.Reader) (io.ReadCloser, error)
+}
+
+// supportedEncodings are the encodings of the responses and of the requests, see DecompressRequest.
+var supportedEncodings = map[string]contentEncoding{
+	"gzip": {
+		encoder: func(level int) encoder {
+			zw, _ := gzip.NewWriterLevel(io.Discard, flateLevel(level))
+			return zw
+		},
+		decoder: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	// deflate is the zlib format (RFC 1950) as specified by RFC 9110, not the raw deflate stream.
+	"deflate": {
+		encoder: func(level int) encoder {
+			zw, _ := zlib.NewWriterLevel(io.Discard, flateLevel(level))
+			return zw
+		},
+		decoder: func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+	},
+	"br": {
+		encoder: func(level int) encoder {
+			return brotli.NewWriterLevel(io.Discard, level)
+		},
+		decoder: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(brotli.NewReader(r)), nil
+		},
+	},
+	"zstd": {
+		encoder: func(level int) encoder {
+			zw, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(1))
+			return zw
+		},
+		decoder: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return zr.IOReadCloser(), nil
+		},
+	},
+}
+
+// defaultLevels are the levels of the encodings, favouring speed over ratio for dynamic responses.
+var defaultLevels = map[string]int{
+	"gzip":    flate.DefaultCompression,
+	"deflate": flate.DefaultCompression,
+	"br":      4,
+	"zstd":    3,
+}
+
+// precompressedExtensions are the extensions of the precompressed variants of static files.
+var precompressedExtensions = map[string]string{
+	"br":   ".br",
+	"zstd": ".zst",
+	"gzip": ".gz",
+}
+
+// flateLevel returns the level when valid for gzip and deflate, the default level otherwise.
+func flateLevel(level int) int {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return flate.DefaultCompression
 	}
-)
+	return level
+}
+
+type compressConfig struct {
+	minSize      int
+	contentTypes []string
+	levels       map[string]int
+	preference   []string
+	static       http.FileSystem
+	encoders     map[string]*compressor
+}
+
+// CompressOption configures CompressResponse.
+type CompressOption func(*compressConfig)
+
+// CompressMinSize sets the size under which responses are sent uncompressed, defaults to DefaultCompressMinSize.
+func CompressMinSize(n int) CompressOption {
+	return func(c *compressConfig) {
+		c.minSize = n
+	}
+}
+
+// CompressContentTypes replaces the media types that are compressed, defaults to DefaultCompressContentTypes.
+// Types can end with a wildcard subtype (text/*) or start with a wildcard followed by a structured suffix (*+json).
+func CompressContentTypes(types ...string) CompressOption {
+	return func(c *compressConfig) {
+		c.contentTypes = types
+	}
+}
+
+// CompressLevel sets the compression level of an encoding: -2 to 9 for gzip and deflate, 0 to 11 for br, 1 to 22 for zstd.
+// Invalid levels fall back to the default level of the encoding.
+func CompressLevel(encoding string, level int) CompressOption {
+	return func(c *compressConfig) {
+		c.levels[strings.ToLower(encoding)] = level
+	}
+}
+
+// CompressPreference sets the encodings offered and the order in which they are chosen when the client accepts them
+// with the same quality, defaults to DefaultCompressPreference.
+func CompressPreference(encodings ...string) CompressOption {
+	return func(c *compressConfig) {
+		c.preference = encodings
+	}
+}
+
+// CompressPrecompressed serves the precompressed variants of the files of root (.br, .zst and .gz files)
+// for GET and HEAD requests when the variant of an accepted encoding is present, e.g.: /app.js.br for /app.js.
+// Requests without variant are handled by the next handler.
+func CompressPrecompressed(root http.FileSystem) CompressOption {
+	return func(c *compressConfig) {
+		c.static = root
+	}
+}
 
-func CompressResponse() func(next http.Handler) http.Handler {
+// CompressResponse compresses the responses with the encoding preferred by the Accept-Encoding header.
+// Responses are compressed by a single pooled encoder, only when they are larger than the minimum size,
+// have a compressible content type and carry a body (HEAD requests, 204 and 304 are left untouched).
+// Content-Length is removed, ETag is weakened and Vary: Accept-Encoding is added.
+// The response writer keeps supporting http.Flusher and http.Hijacker.
+func CompressResponse(opts ...CompressOption) func(next http.Handler) http.Handler {
+	cfg := &compressConfig{
+		minSize:      DefaultCompressMinSize,
+		contentTypes: DefaultCompressContentTypes,
+		levels:       map[string]int{},
+		preference:   DefaultCompressPreference,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cfg.encoders = map[string]*compressor{}
+	for _, encoding := range cfg.preference {
+		encoding = strings.ToLower(encoding)
+		create := supportedEncodings[encoding].encoder
+		if create == nil {
+			continue
+		}
+		level, ok := cfg.levels[encoding]
+		if !ok {
+			level = defaultLevels[encoding]
+		}
+		cfg.encoders[encoding] = newCompressor(func() encoder { return create(level) })
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			supportedContentEncoding := getContentEncoding(r.Header.Get("Accept-Encoding"))
-			cw := &compressWriter{w, supportedContentEncoding}
-			cw.Header().Set("Content-Encoding", supportedContentEncoding)
+			negotiation.AddVary(w.Header(), negotiation.HeaderAcceptEncoding)
+			accepted := cfg.acceptedEncodings(r.Header.Get(negotiation.HeaderAcceptEncoding))
+			if cfg.static != nil && cfg.servePrecompressed(w, r, accepted) {
+				return
+			}
+			encoding := ""
+			for _, e := range accepted {
+				if cfg.encoders[e] != nil {
+					encoding = e
+					break
+				}
+			}
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, cfg: cfg, encoding: encoding}
+			defer cw.close()
 			next.ServeHTTP(cw, r)
 		})
 	}
 }
 
-func getContentEncoding(acceptEncoding string) string {
-	encodings := strings.Split(acceptEncoding, ",")
-	qHeap := make(maxHeap, 0, len(encodings))
-	for _, encoding := range encodings {
-		compress, err := createQCompress(encoding)
-		if err != nil {
+// acceptedEncodings returns the encodings of the preference list accepted by the Accept-Encoding header,
+// ordered by quality then by preference. "*" only matches gzip, the most widely supported encoding.
+func (cfg *compressConfig) acceptedEncodings(acceptEncoding string) []string {
+	values := negotiation.ParseWeighted(acceptEncoding)
+	if len(values) == 0 {
+		return nil
+	}
+	qualities := map[string]float64{}
+	wildcard, hasWildcard := 0.0, false
+	for _, v := range values {
+		algo := strings.ToLower(v.Value)
+		if algo == "*" {
+			if !hasWildcard {
+				wildcard, hasWildcard = v.Quality, true
+			}
 			continue
 		}
-		heap.Push(&qHeap, compress)
+		// values are ordered by quality, the first occurrence is kept
+		if _, ok := qualities[algo]; !ok {
+			qualities[algo] = v.Quality
+		}
 	}
-	for len(qHeap) > 0 {
-		compress := heap.Pop(&qHeap).(qCompress)
-		if supportedEncodings[compress.algo] != nil {
-			if compress.algo == "*" {
-				return defaultEncoding
-			}
-			return compress.algo
+	if _, ok := qualities[defaultEncoding]; !ok && hasWildcard {
+		qualities[defaultEncoding] = wildcard
+	}
+	var encodings []string
+	for _, encoding := range cfg.preference {
+		encoding = strings.ToLower(encoding)
+		if qualities[encoding] > 0 {
+			encodings = append(encodings, encoding)
 		}
 	}
-	return ""
+	sort.SliceStable(encodings, func(i, j int) bool {
+		return qualities[encodings[i]] > qualities[encodings[j]]
+	})
+	return encodings
 }
 
-func createQCompress(encoding string) (qCompress, error) {
-	encoding = strings.TrimSpace(encoding)
-	split := strings.Split(encoding, ";")
-	compress := qCompress{algo: split[0], q: 1.0, qPresent: false}
-	if len(split) > 1 {
-		q := strings.TrimSpace(split[1])
-		if strings.HasPrefix(q, "q=") {
-			qFloat, err := strconv.ParseFloat(q[2:], 64)
-			if err != nil {
-				return compress, err
+// servePrecompressed serves the variant of the requested file for the first accepted encoding having one.
+func (cfg *compressConfig) servePrecompressed(w http.ResponseWriter, r *http.Request, accepted []string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	name := path.Clean("/" + r.URL.Path)
+	for _, encoding := range accepted {
+		ext, ok := precompressedExtensions[encoding]
+		if !ok {
+			continue
+		}
+		f, err := cfg.static.Open(name + ext)
+		if err != nil {
+			continue
+		}
+		stat, err := f.Stat()
+		if err != nil || stat.IsDir() {
+			_ = f.Close()
+			continue
+		}
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			// http.ServeContent would sniff the compressed bytes
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", encoding)
+		http.ServeContent(w, r, name, stat.ModTime(), f)
+		_ = f.Close()
+		return true
+	}
+	return false
+}
+
+func (cfg *compressConfig) compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, t := range cfg.contentTypes {
+		switch {
+		case strings.HasSuffix(t, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+				return true
 			}
-			compress.q = qFloat
-			compress.qPresent = true
+		case strings.HasPrefix(t, "*"):
+			if strings.HasSuffix(mediaType, strings.TrimPrefix(t, "*")) {
+				return true
+			}
+		case t == mediaType:
+			return true
 		}
 	}
-	return compress, nil
+	return false
 }
 
-// Wrapper to handle the compression of the response
+// compressWriter buffers the beginning of the response until the decision to compress it can be made.
 type compressWriter struct {
 	http.ResponseWriter
-	acceptEncoding string
+	cfg      *compressConfig
+	encoding string
+	encoder  encoder
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
 }
 
-func (c *compressWriter) Write(b []byte) (int, error) {
-	if supportedEncodings[c.acceptEncoding] != nil {
-		return supportedEncodings[c.acceptEncoding](c.ResponseWriter, b)
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	if status < http.StatusOK {
+		// informational responses are followed by the final one
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.passthrough()
 	}
-	return c.ResponseWriter.Write(b)
 }
 
-type compressFunc func(w http.ResponseWriter, b []byte) (int, error)
-
-func compressWithGzip(w http.ResponseWriter, b []byte) (int, error) {
-	zw := gzip.NewWriter(w)
-	defer zw.Close()
-	return zw.Write(b)
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		h := cw.Header()
+		if h.Get("Content-Encoding") != "" || (h.Get("Content-Type") != "" && !cw.cfg.compressible(h.Get("Content-Type"))) {
+			cw.passthrough()
+		} else {
+			cw.buf = append(cw.buf, b...)
+			if len(cw.buf) >= cw.cfg.minSize {
+				if err := cw.decide(); err != nil {
+					return 0, err
+				}
+			}
+			return len(b), nil
+		}
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
 }
 
-func compressWithDeflate(w http.ResponseWriter, b []byte) (int, error) {
-	zw, err := flate.NewWriter(w, flate.DefaultCompression)
-	if err != nil {
-		return 0, errors.Wrap(err, "failed to create deflate writer")
+// decide compresses the buffered response when its content type is compressible.
+func (cw *compressWriter) decide() error {
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// sniff before compressing, net/http would sniff the compressed bytes
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if !cw.cfg.compressible(h.Get("Content-Type")) {
+		return cw.flushBuffer()
+	}
+	cw.decided = true
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", cw.encoding)
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
 	}
-	defer zw.Close()
-	return zw.Write(b)
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.encoder = cw.cfg.encoders[cw.encoding].get(cw.ResponseWriter)
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.encoder.Write(buf)
+	return errors.Wrap(err, "failed to compress response")
 }
 
-type qCompress struct {
-	q        float64
-	algo     string
-	qPresent bool
+// passthrough sends the response uncompressed.
+func (cw *compressWriter) passthrough() {
+	_ = cw.flushBuffer()
 }
 
-// Heap to sort the compressions by q value
+func (cw *compressWriter) flushBuffer() error {
+	cw.decided = true
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
 
-type maxHeap []qCompress
+// Flush compresses and sends the buffered response, streamed responses are compressed regardless of the minimum size.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if !cw.wroteHeader {
+			cw.WriteHeader(http.StatusOK)
+		}
+		if !cw.decided {
+			_ = cw.decide()
+		}
+	}
+	if cw.encoder != nil {
+		_ = cw.encoder.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
 
-func (h *maxHeap) Push(x any) {
-	*h = append(*h, x.(qCompress))
+// Hijack hands over the connection, the response is no longer compressed.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+	cw.decided = true
+	return hj.Hijack()
 }
 
-func (h *maxHeap) Pop() any {
-	old := *h
-	n := len(old)
-	x := old[n-1]
-	*h = old[0 : n-1]
-	return x
+// Unwrap returns the wrapped response writer, used by http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
 }
 
-func (h maxHeap) Len() int { return len(h) }
-func (h maxHeap) Less(i, j int) bool {
-	if h[i].q == h[j].q {
-		return h[i].qPresent
+// close sends the response left in the buffer and releases the encoder.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.wroteHeader || len(cw.buf) > 0 {
+			cw.passthrough()
+		}
+		return
+	}
+	if cw.encoder != nil {
+		_ = cw.encoder.Close()
+		cw.cfg.encoders[cw.encoding].put(cw.encoder)
+		cw.encoder = nil
 	}
-	return h[i].q > h[j].q
 }
-func (h maxHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
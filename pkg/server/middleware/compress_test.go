@@ -3,10 +3,16 @@ package middleware_test
 import (
 	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing/fstest"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -24,7 +30,7 @@ var _ = Describe("Compress", func() {
 	)
 
 	BeforeEach(func() {
-		compressMiddleware = middleware.CompressResponse()
+		compressMiddleware = middleware.CompressResponse(middleware.CompressMinSize(0))
 		handlerFunc = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("test"))
@@ -54,7 +60,7 @@ var _ = Describe("Compress", func() {
 
 	When("Encoding is not supported", func() {
 		BeforeEach(func() {
-			request.Header.Set("Accept-Encoding", "br")
+			request.Header.Set("Accept-Encoding", "compress")
 		})
 		It("should not compress", func() {
 			compressMiddleware(handlerFunc).ServeHTTP(responseRecorder, request)
@@ -78,6 +84,23 @@ var _ = Describe("Compress", func() {
 			compressMiddleware(handlerFunc).ServeHTTP(responseRecorder, request)
 			Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
 		})
+		It("should prefer the encoding preferred by the server", func() {
+			request.Header.Set("Accept-Encoding", "gzip, deflate, zstd, br")
+			compressMiddleware(handlerFunc).ServeHTTP(responseRecorder, request)
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("br"))
+		})
+		It("should follow the configured preference", func() {
+			request.Header.Set("Accept-Encoding", "gzip, zstd, br")
+			middleware.CompressResponse(middleware.CompressMinSize(0), middleware.CompressPreference("zstd", "gzip"))(handlerFunc).
+				ServeHTTP(responseRecorder, request)
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("zstd"))
+		})
+		It("should not offer the encodings missing from the preference", func() {
+			request.Header.Set("Accept-Encoding", "br")
+			middleware.CompressResponse(middleware.CompressMinSize(0), middleware.CompressPreference("gzip"))(handlerFunc).
+				ServeHTTP(responseRecorder, request)
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(BeEmpty())
+		})
 	})
 
 	Context("Testing that content body is compressed", func() {
@@ -104,11 +127,12 @@ var _ = Describe("Compress", func() {
 			BeforeEach(func() {
 				request.Header.Set("Accept-Encoding", "deflate")
 			})
-			It("should compress the body with deflate algorithm", func() {
+			It("should compress the body with the zlib format", func() {
 				compressMiddleware(handlerFunc).ServeHTTP(responseRecorder, request)
-				reader := flate.NewReader(responseRecorder.Body)
+				reader, err := zlib.NewReader(responseRecorder.Body)
+				Expect(err).To(BeNil())
 				defer reader.Close()
-				// unzip the body with gzip reader
+				// inflate the body with zlib reader
 				got := make([]byte, len(responseRecorder.Body.Bytes()))
 				n, err := reader.Read(got)
 				if err != nil && err != io.EOF {
@@ -118,5 +142,180 @@ var _ = Describe("Compress", func() {
 				Expect(string(got[:n])).To(Equal("test"))
 			})
 		})
+		When("Request asks for br compression", func() {
+			BeforeEach(func() {
+				request.Header.Set("Accept-Encoding", "br")
+			})
+			It("should compress the body with brotli algorithm", func() {
+				compressMiddleware(handlerFunc).ServeHTTP(responseRecorder, request)
+				Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("br"))
+				got, err := io.ReadAll(brotli.NewReader(responseRecorder.Body))
+				Expect(err).To(BeNil())
+				Expect(string(got)).To(Equal("test"))
+			})
+		})
+		When("Request asks for zstd compression", func() {
+			BeforeEach(func() {
+				request.Header.Set("Accept-Encoding", "zstd")
+			})
+			It("should compress the body with zstd algorithm", func() {
+				compressMiddleware(handlerFunc).ServeHTTP(responseRecorder, request)
+				Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("zstd"))
+				reader, err := zstd.NewReader(responseRecorder.Body)
+				Expect(err).To(BeNil())
+				defer reader.Close()
+				got, err := io.ReadAll(reader)
+				Expect(err).To(BeNil())
+				Expect(string(got)).To(Equal("test"))
+			})
+		})
+		When("A compression level is configured", func() {
+			It("should compress the body at this level", func() {
+				large := strings.Repeat("compressible ", 200)
+				handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(large))
+				})
+				sizes := map[int]int{}
+				for _, level := range []int{flate.NoCompression, flate.BestCompression} {
+					recorder := httptest.NewRecorder()
+					request.Header.Set("Accept-Encoding", "gzip")
+					middleware.CompressResponse(middleware.CompressLevel("gzip", level))(handler).ServeHTTP(recorder, request)
+					Expect(recorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
+					sizes[level] = recorder.Body.Len()
+				}
+				Expect(sizes[flate.NoCompression]).To(BeNumerically(">", len(large)))
+				Expect(sizes[flate.BestCompression]).To(BeNumerically("<", len(large)/10))
+			})
+		})
+	})
+
+	Context("Testing precompressed static files", func() {
+		var (
+			static http.FileSystem
+			next   http.Handler
+		)
+		BeforeEach(func() {
+			static = http.FS(fstest.MapFS{
+				"app.js":         {Data: []byte("console.log('app')")},
+				"app.js.br":      {Data: []byte("brotli")},
+				"app.js.gz":      {Data: []byte("gzip")},
+				"style.css.gz":   {Data: []byte("gzip")},
+				"directory.gz/a": {Data: []byte("a")},
+			})
+			next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = w.Write([]byte("next"))
+			})
+		})
+		serve := func(path, acceptEncoding string) {
+			responseRecorder = httptest.NewRecorder()
+			request, _ = http.NewRequest(http.MethodGet, path, nil)
+			request.Header.Set("Accept-Encoding", acceptEncoding)
+			middleware.CompressResponse(middleware.CompressMinSize(0), middleware.CompressPrecompressed(static))(next).
+				ServeHTTP(responseRecorder, request)
+		}
+
+		It("should serve the variant of the preferred encoding", func() {
+			serve("/app.js", "gzip, br")
+			Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("br"))
+			Expect(responseRecorder.Header().Get("Content-Type")).To(HavePrefix("text/javascript"))
+			Expect(responseRecorder.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(responseRecorder.Body.String()).To(Equal("brotli"))
+		})
+		It("should fall back to the variants present", func() {
+			serve("/style.css", "br, gzip;q=0.5")
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(responseRecorder.Header().Get("Content-Type")).To(HavePrefix("text/css"))
+			Expect(responseRecorder.Body.String()).To(Equal("gzip"))
+		})
+		It("should call the next handler without acceptable variant", func() {
+			serve("/app.js", "deflate")
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("deflate"))
+			serve("/directory", "gzip")
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(gzipBody(responseRecorder)).To(Equal("next"))
+		})
+	})
+
+	Context("Testing when the response is compressed", func() {
+		large := strings.Repeat("compressible ", 200)
+		serve := func(handler http.HandlerFunc, opts ...middleware.CompressOption) {
+			request.Header.Set("Accept-Encoding", "gzip")
+			middleware.CompressResponse(opts...)(handler).ServeHTTP(responseRecorder, request)
+		}
+
+		It("should produce a single stream for multiple writes", func() {
+			serve(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", strconv.Itoa(2*len(large)))
+				w.Header().Set("ETag", `"v1"`)
+				_, _ = w.Write([]byte(large))
+				_, _ = w.Write([]byte(large))
+			})
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(responseRecorder.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(responseRecorder.Header().Get("Content-Length")).To(BeEmpty())
+			Expect(responseRecorder.Header().Get("ETag")).To(Equal(`W/"v1"`))
+			Expect(gzipBody(responseRecorder)).To(Equal(large + large))
+		})
+		It("should not compress responses smaller than the minimum size", func() {
+			serve(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte("{}"))
+			})
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(BeEmpty())
+			Expect(responseRecorder.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(responseRecorder.Body.String()).To(Equal("{}"))
+		})
+		It("should not compress content types outside the allowlist", func() {
+			serve(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				_, _ = w.Write([]byte(large))
+			})
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(BeEmpty())
+			Expect(responseRecorder.Body.String()).To(Equal(large))
+		})
+		It("should compress structured suffixes", func() {
+			serve(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/vnd.athosone.book+yaml; v=v2")
+				_, _ = w.Write([]byte(large))
+			})
+			Expect(gzipBody(responseRecorder)).To(Equal(large))
+		})
+		It("should not compress responses without body", func() {
+			serve(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+			Expect(responseRecorder.Code).To(Equal(http.StatusNoContent))
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(BeEmpty())
+		})
+		It("should not compress HEAD requests", func() {
+			request.Method = http.MethodHead
+			serve(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = w.Write([]byte(large))
+			})
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(BeEmpty())
+		})
+		It("should flush the compressed stream", func() {
+			serve(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				_, _ = w.Write([]byte("{}\n"))
+				w.(http.Flusher).Flush()
+				Expect(responseRecorder.Flushed).To(BeTrue())
+				Expect(responseRecorder.Body.Len()).To(BeNumerically(">", 0))
+			})
+			Expect(responseRecorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(gzipBody(responseRecorder)).To(Equal("{}\n"))
+		})
 	})
 })
+
+func gzipBody(recorder *httptest.ResponseRecorder) string {
+	reader, err := gzip.NewReader(recorder.Body)
+	Expect(err).To(BeNil())
+	body, err := io.ReadAll(reader)
+	Expect(err).To(BeNil())
+	return string(body)
+}
@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	glogger "github.com/athosone/golib/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// maxRequestIDLength is the length above which incoming request ids are replaced.
+const maxRequestIDLength = 128
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent header")
+
+type (
+	contextRequestIDKey    struct{}
+	contextTraceContextKey struct{}
+)
+
+// TraceContext is the W3C trace context of a request: https://www.w3.org/TR/trace-context/
+type TraceContext struct {
+	// TraceID is the 32 hex digits id of the whole trace.
+	TraceID string
+	// ParentID is the 16 hex digits id of the span of the caller, empty when the trace starts with the request.
+	ParentID string
+	// SpanID is the 16 hex digits id of the span of the request, used as parent id of the outbound calls.
+	SpanID string
+	// Flags are the trace flags, 01 when the trace is sampled.
+	Flags byte
+	// State is the vendor specific tracestate header, propagated as is.
+	State string
+}
+
+// NewTraceContext starts a sampled trace.
+func NewTraceContext() TraceContext {
+	return TraceContext{TraceID: randomHex(16), SpanID: randomHex(8), Flags: 1}
+}
+
+// ParseTraceparent parses the traceparent header: version-traceid-parentid-flags, the span id is left empty.
+// Versions above 00 are parsed as 00 as required by the specification, ignoring the additional fields.
+func ParseTraceparent(header string) (TraceContext, error) {
+	header = strings.TrimSpace(header)
+	if len(header) < 55 || (len(header) > 55 && header[55] != '-') {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	version := header[0:2]
+	if !isLowerHex(version) || version == "ff" || (version == "00" && len(header) != 55) {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	if header[2] != '-' || header[35] != '-' || header[52] != '-' {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	traceID, parentID, flags := header[3:35], header[36:52], header[53:55]
+	if !isLowerHex(traceID) || !isLowerHex(parentID) || !isLowerHex(flags) ||
+		strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	b, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, ParentID: parentID, Flags: b[0]}, nil
+}
+
+// Traceparent returns the traceparent header of the span.
+func (tc TraceContext) Traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+// Sampled is true when the caller may have recorded the trace.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&1 == 1
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	// crypto/rand does not fail on the supported platforms
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID is true for non-empty request ids of printable ascii characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestIDContext returns a context carrying the request id.
+func NewRequestIDContext(parent context.Context, id string) context.Context {
+	return context.WithValue(parent, contextRequestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id stored by Correlation, empty when none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextRequestIDKey{}).(string)
+	return id
+}
+
+// NewTraceContextContext returns a context carrying the trace context.
+func NewTraceContextContext(parent context.Context, tc TraceContext) context.Context {
+	return context.WithValue(parent, contextTraceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the trace context stored by Correlation.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(contextTraceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// correlationFields returns the logger fields of the request id and the trace context of the context.
+func correlationFields(ctx context.Context) []any {
+	var fields []any
+	if id := RequestIDFromContext(ctx); id != "" {
+		fields = append(fields, "request_id", id)
+	}
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		fields = append(fields, "trace_id", tc.TraceID, "span_id", tc.SpanID)
+	}
+	return fields
+}
+
+// Correlation reads the X-Request-ID header, or generates it when missing or invalid,
+// and continues the trace of the traceparent and tracestate headers, or starts a new one.
+// Both are stored in the request context (see RequestIDFromContext and TraceContextFromContext),
+// echoed in the response headers and added to the logger of the context (request_id, trace_id and span_id fields),
+// InjectLoggerInRequest adds them as well when it runs after Correlation.
+// Outbound requests propagate them with CorrelationTransport.
+func Correlation() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(HeaderRequestID)
+			if !validRequestID(id) {
+				id = randomHex(16)
+			}
+			var tc TraceContext
+			if parent, err := ParseTraceparent(r.Header.Get(HeaderTraceparent)); err == nil {
+				tc = parent
+				tc.SpanID = randomHex(8)
+				tc.State = strings.Join(r.Header.Values(HeaderTracestate), ",")
+			} else {
+				tc = NewTraceContext()
+			}
+
+			w.Header().Set(HeaderRequestID, id)
+			w.Header().Set(HeaderTraceparent, tc.Traceparent())
+			if tc.State != "" {
+				w.Header().Set(HeaderTracestate, tc.State)
+			}
+
+			ctx := NewTraceContextContext(NewRequestIDContext(r.Context(), id), tc)
+			logger := glogger.LoggerFromContextOrDefault(ctx).With(correlationFields(ctx)...)
+			ctx = glogger.NewContextWithLogger(ctx, logger)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type correlationTransport struct {
+	base http.RoundTripper
+}
+
+// CorrelationTransport returns a RoundTripper propagating the request id and the trace context of the request context
+// to the outbound requests, base defaults to http.DefaultTransport.
+func CorrelationTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &correlationTransport{base: base}
+}
+
+func (t *correlationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	id := RequestIDFromContext(ctx)
+	tc, ok := TraceContextFromContext(ctx)
+	if id == "" && !ok {
+		return t.base.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the request
+	req = req.Clone(ctx)
+	if id != "" {
+		req.Header.Set(HeaderRequestID, id)
+	}
+	if ok {
+		req.Header.Set(HeaderTraceparent, tc.Traceparent())
+		if tc.State != "" {
+			req.Header.Set(HeaderTracestate, tc.State)
+		} else {
+			req.Header.Del(HeaderTracestate)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
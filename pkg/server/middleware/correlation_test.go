@@ -0,0 +1,150 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	glogger "github.com/athosone/golib/pkg/logger"
+	"github.com/athosone/golib/pkg/server/middleware"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+var _ = Describe("Correlation", func() {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	var (
+		request  *http.Request
+		recorder *httptest.ResponseRecorder
+		captured *http.Request
+		handler  http.Handler
+	)
+
+	BeforeEach(func() {
+		request = httptest.NewRequest(http.MethodGet, "/books", nil)
+		recorder = httptest.NewRecorder()
+		captured = nil
+		handler = middleware.Correlation()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			captured = r
+		}))
+	})
+
+	Context("ParseTraceparent", func() {
+		It("should parse a valid header", func() {
+			tc, err := middleware.ParseTraceparent(traceparent)
+			Expect(err).To(BeNil())
+			Expect(tc.TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(tc.ParentID).To(Equal("00f067aa0ba902b7"))
+			Expect(tc.Sampled()).To(BeTrue())
+		})
+		It("should parse the future versions", func() {
+			_, err := middleware.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+			Expect(err).To(BeNil())
+		})
+		DescribeTable("should reject invalid headers",
+			func(header string) {
+				_, err := middleware.ParseTraceparent(header)
+				Expect(err).To(MatchError(middleware.ErrInvalidTraceparent))
+			},
+			Entry("empty", ""),
+			Entry("version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+			Entry("version 00 with extra fields", traceparent+"-extra"),
+			Entry("upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"),
+			Entry("zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"),
+			Entry("zero parent id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"),
+			Entry("missing separator", "00-4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7-01"),
+		)
+	})
+
+	It("should continue the incoming trace and keep the request id", func() {
+		request.Header.Set(middleware.HeaderRequestID, "req-42")
+		request.Header.Set(middleware.HeaderTraceparent, traceparent)
+		request.Header.Add(middleware.HeaderTracestate, "congo=t61rcWkgMzE")
+		request.Header.Add(middleware.HeaderTracestate, "rojo=00f067aa0ba902b7")
+		handler.ServeHTTP(recorder, request)
+
+		Expect(middleware.RequestIDFromContext(captured.Context())).To(Equal("req-42"))
+		tc, ok := middleware.TraceContextFromContext(captured.Context())
+		Expect(ok).To(BeTrue())
+		Expect(tc.TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(tc.ParentID).To(Equal("00f067aa0ba902b7"))
+		Expect(tc.SpanID).To(MatchRegexp("^[0-9a-f]{16}$"))
+		Expect(tc.SpanID).NotTo(Equal(tc.ParentID))
+		Expect(tc.State).To(Equal("congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"))
+
+		Expect(recorder.Header().Get(middleware.HeaderRequestID)).To(Equal("req-42"))
+		Expect(recorder.Header().Get(middleware.HeaderTraceparent)).To(Equal(tc.Traceparent()))
+		Expect(recorder.Header().Get(middleware.HeaderTracestate)).To(Equal(tc.State))
+	})
+
+	It("should generate the request id and start a trace", func() {
+		request.Header.Set(middleware.HeaderRequestID, "invalid id")
+		request.Header.Set(middleware.HeaderTraceparent, "00-invalid")
+		request.Header.Set(middleware.HeaderTracestate, "congo=t61rcWkgMzE")
+		handler.ServeHTTP(recorder, request)
+
+		id := middleware.RequestIDFromContext(captured.Context())
+		Expect(id).To(MatchRegexp("^[0-9a-f]{32}$"))
+		tc, _ := middleware.TraceContextFromContext(captured.Context())
+		Expect(tc.TraceID).To(MatchRegexp("^[0-9a-f]{32}$"))
+		Expect(tc.ParentID).To(BeEmpty())
+		Expect(tc.State).To(BeEmpty())
+		Expect(tc.Sampled()).To(BeTrue())
+		Expect(recorder.Header().Get(middleware.HeaderRequestID)).To(Equal(id))
+		Expect(recorder.Header().Get(middleware.HeaderTracestate)).To(BeEmpty())
+	})
+
+	It("should add the ids to the logger of the context", func() {
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := zap.New(core).Sugar()
+		request.Header.Set(middleware.HeaderRequestID, "req-42")
+		request.Header.Set(middleware.HeaderTraceparent, traceparent)
+		logged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			glogger.LoggerFromContextOrDefault(r.Context()).Info("handled")
+		})
+		inject := middleware.InjectLoggerInRequest(func(*http.Request) *zap.SugaredLogger { return logger })
+
+		// the logger is injected before and after the correlation
+		inject(middleware.Correlation()(logged)).ServeHTTP(recorder, request)
+		middleware.Correlation()(inject(logged)).ServeHTTP(recorder, request)
+
+		Expect(logs.Len()).To(Equal(2))
+		for _, entry := range logs.All() {
+			fields := entry.ContextMap()
+			Expect(fields).To(HaveKeyWithValue("request_id", "req-42"))
+			Expect(fields).To(HaveKeyWithValue("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(fields).To(HaveKey("span_id"))
+		}
+	})
+
+	It("should propagate the ids on outbound requests", func() {
+		request.Header.Set(middleware.HeaderTraceparent, traceparent)
+		request.Header.Set(middleware.HeaderTracestate, "congo=t61rcWkgMzE")
+		handler.ServeHTTP(recorder, request)
+
+		var outbound *http.Request
+		client := &http.Client{Transport: middleware.CorrelationTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			outbound = r
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+		}))}
+		call, _ := http.NewRequestWithContext(captured.Context(), http.MethodGet, "http://books.local/books", nil)
+		_, err := client.Do(call)
+		Expect(err).To(BeNil())
+
+		tc, _ := middleware.TraceContextFromContext(captured.Context())
+		Expect(outbound.Header.Get(middleware.HeaderRequestID)).To(Equal(middleware.RequestIDFromContext(captured.Context())))
+		Expect(outbound.Header.Get(middleware.HeaderTraceparent)).To(Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-" + tc.SpanID + "-01"))
+		Expect(outbound.Header.Get(middleware.HeaderTracestate)).To(Equal("congo=t61rcWkgMzE"))
+		Expect(call.Header).To(BeEmpty())
+	})
+})
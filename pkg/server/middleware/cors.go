@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/athosone/golib/pkg/server/negotiation"
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+const (
+	HeaderOrigin                        = "Origin"
+	HeaderAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+)
+
+var (
+	// DefaultCORSMethods are the methods allowed when no router is set.
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	// DefaultCORSHeaders are the request headers allowed by default.
+	DefaultCORSHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type", "Authorization",
+		HeaderRequestID, HeaderTraceparent, HeaderTracestate}
+)
+
+// subdomainOrigin matches the subdomains of an origin allowed with a wildcard, e.g.: https://*.example.com
+type subdomainOrigin struct {
+	scheme string
+	// domain starts with a dot: .example.com
+	domain string
+}
+
+func (s subdomainOrigin) match(origin string) bool {
+	return strings.HasPrefix(origin, s.scheme) && strings.HasSuffix(origin, s.domain) &&
+		len(origin) > len(s.scheme)+len(s.domain)
+}
+
+type corsConfig struct {
+	anyOrigin   bool
+	origins     map[string]struct{}
+	subdomains  []subdomainOrigin
+	patterns    []*regexp.Regexp
+	originFuncs []func(r *http.Request, origin string) bool
+	methods     []string
+	headers     []string
+	anyHeader   bool
+	exposed     []string
+	credentials bool
+	maxAge      time.Duration
+	router      *routing.GRouter
+}
+
+// CORSOption configures CORS.
+type CORSOption func(*corsConfig)
+
+// CORSAllowOrigins allows the origins: exact origins (https://example.com),
+// subdomains with a wildcard (https://*.example.com) or any origin with "*".
+// Any origin cannot be combined with CORSAllowCredentials.
+func CORSAllowOrigins(origins ...string) CORSOption {
+	return func(c *corsConfig) {
+		for _, origin := range origins {
+			origin = strings.ToLower(origin)
+			switch {
+			case origin == "*":
+				c.anyOrigin = true
+			case strings.Contains(origin, "://*."):
+				scheme, domain, _ := strings.Cut(origin, "://*")
+				c.subdomains = append(c.subdomains, subdomainOrigin{scheme: scheme + "://", domain: domain})
+			default:
+				c.origins[origin] = struct{}{}
+			}
+		}
+	}
+}
+
+// CORSAllowOriginRegexp allows the origins matching the regular expression.
+func CORSAllowOriginRegexp(pattern *regexp.Regexp) CORSOption {
+	return func(c *corsConfig) {
+		c.patterns = append(c.patterns, pattern)
+	}
+}
+
+// CORSAllowOriginFunc allows the origins for which fn returns true.
+func CORSAllowOriginFunc(fn func(r *http.Request, origin string) bool) CORSOption {
+	return func(c *corsConfig) {
+		c.originFuncs = append(c.originFuncs, fn)
+	}
+}
+
+// CORSAllowMethods sets the methods allowed when no router is set, defaults to DefaultCORSMethods.
+func CORSAllowMethods(methods ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.methods = methods
+	}
+}
+
+// CORSAllowHeaders sets the request headers allowed, defaults to DefaultCORSHeaders.
+// "*" allows the headers requested by the preflights.
+func CORSAllowHeaders(headers ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.headers = nil
+		c.anyHeader = false
+		for _, header := range headers {
+			if header == "*" {
+				c.anyHeader = true
+				continue
+			}
+			c.headers = append(c.headers, http.CanonicalHeaderKey(header))
+		}
+	}
+}
+
+// CORSExposeHeaders sets the response headers readable by the browsers besides the CORS-safelisted ones.
+func CORSExposeHeaders(headers ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.exposed = headers
+	}
+}
+
+// CORSAllowCredentials allows the requests with cookies or authorization headers, the origin is echoed.
+// CORS panics when it is combined with any origin ("*"), which would let every site make credentialed requests.
+func CORSAllowCredentials() CORSOption {
+	return func(c *corsConfig) {
+		c.credentials = true
+	}
+}
+
+// CORSMaxAge sets how long the browsers can cache the preflight responses.
+func CORSMaxAge(d time.Duration) CORSOption {
+	return func(c *corsConfig) {
+		c.maxAge = d
+	}
+}
+
+// CORSRouter derives the preflight responses from the routes of the router matching the path:
+// the allowed methods are the ones of the routes (see GRouter.AllowedMethods) and the media types accepted
+// by POST and PATCH are advertised in Accept-Post and Accept-Patch.
+// Preflights of paths without route reach the router and are answered with 404.
+func CORSRouter(router *routing.GRouter) CORSOption {
+	return func(c *corsConfig) {
+		c.router = router
+	}
+}
+
+// CORS implements the Cross-Origin Resource Sharing protocol: https://fetch.spec.whatwg.org/#http-cors-protocol
+// The requests of the allowed origins get the Access-Control-Allow-* headers,
+// the preflights are answered with 204 without calling the next handler.
+// Requests of other origins are served without CORS headers so that the browsers block them.
+func CORS(opts ...CORSOption) func(next http.Handler) http.Handler {
+	cfg := &corsConfig{
+		origins: map[string]struct{}{},
+		methods: DefaultCORSMethods,
+		headers: DefaultCORSHeaders,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.anyOrigin && cfg.credentials {
+		panic("CORS: credentials cannot be allowed for any origin")
+	}
+	allowedHeaders := strings.Join(cfg.headers, ", ")
+	exposedHeaders := strings.Join(cfg.exposed, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			preflight := r.Method == http.MethodOptions && r.Header.Get(HeaderAccessControlRequestMethod) != ""
+			h := w.Header()
+			if preflight {
+				negotiation.AddVary(h, HeaderOrigin, HeaderAccessControlRequestMethod, HeaderAccessControlRequestHeaders)
+			} else {
+				negotiation.AddVary(h, HeaderOrigin)
+			}
+			origin := r.Header.Get(HeaderOrigin)
+			if origin == "" || !cfg.allowed(r, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if cfg.anyOrigin {
+				h.Set(HeaderAccessControlAllowOrigin, "*")
+			} else {
+				h.Set(HeaderAccessControlAllowOrigin, origin)
+			}
+			if cfg.credentials {
+				h.Set(HeaderAccessControlAllowCredentials, "true")
+			}
+			if !preflight {
+				if exposedHeaders != "" {
+					h.Set(HeaderAccessControlExposeHeaders, exposedHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			methods := cfg.methods
+			if cfg.router != nil {
+				router := cfg.router.Lookup(r.URL.Path)
+				if router == nil {
+					next.ServeHTTP(w, r)
+					return
+				}
+				methods = router.AllowedMethods()
+				for _, method := range []string{http.MethodPost, http.MethodPatch} {
+					if accepted := router.AcceptedMediaTypes(method); len(accepted) > 0 {
+						h.Set(fmt.Sprintf("Accept-%s", method), strings.Join(accepted, ", "))
+					}
+				}
+			}
+			h.Set(HeaderAccessControlAllowMethods, strings.Join(methods, ", "))
+			if requested := r.Header.Get(HeaderAccessControlRequestHeaders); cfg.anyHeader && requested != "" {
+				h.Set(HeaderAccessControlAllowHeaders, requested)
+			} else if allowedHeaders != "" {
+				h.Set(HeaderAccessControlAllowHeaders, allowedHeaders)
+			}
+			if cfg.maxAge > 0 {
+				h.Set(HeaderAccessControlMaxAge, strconv.Itoa(int(cfg.maxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+func (c *corsConfig) allowed(r *http.Request, origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if _, ok := c.origins[lower]; ok {
+		return true
+	}
+	for _, sub := range c.subdomains {
+		if sub.match(lower) {
+			return true
+		}
+	}
+	for _, pattern := range c.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	for _, fn := range c.originFuncs {
+		if fn(r, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server/middleware"
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+var _ = Describe("CORS", func() {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	preflight := func(handler http.Handler, path, origin, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", "content-type, x-custom")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	get := func(handler http.Handler, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	DescribeTable("should allow the configured origins",
+		func(origin string, allowed bool) {
+			handler := middleware.CORS(
+				middleware.CORSAllowOrigins("https://example.com", "https://*.athosone.com"),
+				middleware.CORSAllowOriginRegexp(regexp.MustCompile(`^http://localhost:\d+$`)),
+				middleware.CORSAllowOriginFunc(func(r *http.Request, origin string) bool {
+					return strings.HasSuffix(origin, ".internal")
+				}),
+			)(ok)
+			recorder := get(handler, origin)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Values("Vary")).To(ContainElement("Origin"))
+			if allowed {
+				Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(Equal(origin))
+			} else {
+				Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+			}
+		},
+		Entry("exact", "https://example.com", true),
+		Entry("exact case insensitive", "https://EXAMPLE.com", true),
+		Entry("other scheme", "http://example.com", false),
+		Entry("wildcard subdomain", "https://api.athosone.com", true),
+		Entry("nested subdomain", "https://v1.api.athosone.com", true),
+		Entry("wildcard without subdomain", "https://athosone.com", false),
+		Entry("suffix of another domain", "https://evilathosone.com", false),
+		Entry("regexp", "http://localhost:3000", true),
+		Entry("func", "http://books.internal", true),
+		Entry("unknown", "https://evil.com", false),
+	)
+
+	It("should answer any origin with a wildcard", func() {
+		recorder := get(middleware.CORS(middleware.CORSAllowOrigins("*"))(ok), "https://example.com")
+		Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(Equal("*"))
+	})
+
+	It("should reject the credentials for any origin", func() {
+		Expect(func() {
+			middleware.CORS(middleware.CORSAllowOrigins("*"), middleware.CORSAllowCredentials())
+		}).To(Panic())
+	})
+
+	It("should echo the origin when credentials are allowed", func() {
+		recorder := get(middleware.CORS(middleware.CORSAllowOrigins("https://example.com"), middleware.CORSAllowCredentials(),
+			middleware.CORSExposeHeaders("ETag", "Link"))(ok), "https://example.com")
+		Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://example.com"))
+		Expect(recorder.Header().Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+		Expect(recorder.Header().Get("Access-Control-Expose-Headers")).To(Equal("ETag, Link"))
+	})
+
+	It("should ignore the requests without origin", func() {
+		recorder := get(middleware.CORS(middleware.CORSAllowOrigins("*"))(ok), "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+	})
+
+	It("should answer the preflights with the configured methods and headers", func() {
+		handler := middleware.CORS(
+			middleware.CORSAllowOrigins("https://example.com"),
+			middleware.CORSAllowHeaders("Content-Type"),
+			middleware.CORSMaxAge(10*time.Minute),
+		)(http.NotFoundHandler())
+		recorder := preflight(handler, "/books", "https://example.com", http.MethodPost)
+
+		Expect(recorder.Code).To(Equal(http.StatusNoContent))
+		Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://example.com"))
+		Expect(recorder.Header().Get("Access-Control-Allow-Methods")).To(Equal("GET, HEAD, POST"))
+		Expect(recorder.Header().Get("Access-Control-Allow-Headers")).To(Equal("Content-Type"))
+		Expect(recorder.Header().Get("Access-Control-Max-Age")).To(Equal("600"))
+		Expect(recorder.Header().Values("Vary")).To(ContainElements("Origin", "Access-Control-Request-Method"))
+	})
+
+	It("should reflect the requested headers when any header is allowed", func() {
+		handler := middleware.CORS(middleware.CORSAllowOrigins("*"), middleware.CORSAllowHeaders("*"))(ok)
+		recorder := preflight(handler, "/books", "https://example.com", http.MethodPost)
+		Expect(recorder.Header().Get("Access-Control-Allow-Headers")).To(Equal("content-type, x-custom"))
+	})
+
+	It("should not answer the preflights of other origins", func() {
+		handler := middleware.CORS(middleware.CORSAllowOrigins("https://example.com"))(http.NotFoundHandler())
+		recorder := preflight(handler, "/books", "https://evil.com", http.MethodPost)
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+		Expect(recorder.Header().Get("Access-Control-Allow-Methods")).To(BeEmpty())
+	})
+
+	Context("with a GRouter", func() {
+		var handler http.Handler
+
+		BeforeEach(func() {
+			router := routing.NewRouter()
+			router.Route("/books", func(books *routing.GRouter) {
+				books.Get(ok).Produce("application/vnd.athosone.book+json; v=v1")
+				books.Post(ok).Consume("application/vnd.athosone.book+json; v=v1", "application/vnd.athosone.book+json; v=v2")
+			})
+			router.Route("/books/{id}", func(book *routing.GRouter) {
+				book.Get(ok).Produce("application/vnd.athosone.book+json; v=v1")
+				book.Delete(ok)
+			})
+			handler = middleware.CORS(middleware.CORSAllowOrigins("https://example.com"), middleware.CORSRouter(router))(router)
+		})
+
+		It("should derive the methods and the media types of the routes", func() {
+			recorder := preflight(handler, "/books", "https://example.com", http.MethodPost)
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(recorder.Header().Get("Access-Control-Allow-Methods")).To(Equal("GET, HEAD, POST, OPTIONS"))
+			Expect(recorder.Header().Get("Accept-Post")).To(Equal("application/vnd.athosone.book+json; v=v1, application/vnd.athosone.book+json; v=v2"))
+
+			recorder = preflight(handler, "/books/42", "https://example.com", http.MethodDelete)
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(recorder.Header().Get("Access-Control-Allow-Methods")).To(Equal("GET, HEAD, DELETE, OPTIONS"))
+			Expect(recorder.Header().Get("Accept-Post")).To(BeEmpty())
+		})
+
+		It("should let the router answer the paths without route", func() {
+			recorder := preflight(handler, "/authors", "https://example.com", http.MethodGet)
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(recorder.Header().Get("Access-Control-Allow-Methods")).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/athosone/golib/pkg/server/negotiation"
+	"github.com/athosone/golib/pkg/server/renderer"
+	"github.com/pkg/errors"
+)
+
+// DefaultDecompressMaxSize is the maximum size of a decompressed request body.
+const DefaultDecompressMaxSize = 10 << 20
+
+type decompressConfig struct {
+	maxSize int64
+}
+
+// DecompressOption configures DecompressRequest.
+type DecompressOption func(*decompressConfig)
+
+// DecompressMaxSize sets the maximum size of the decompressed body, defaults to DefaultDecompressMaxSize.
+// A size of 0 or less disables the limit.
+func DecompressMaxSize(n int64) DecompressOption {
+	return func(c *decompressConfig) {
+		c.maxSize = n
+	}
+}
+
+// DecompressRequest decodes the request bodies sent with a Content-Encoding supported by CompressResponse
+// (gzip, deflate, br or zstd).
+// The Content-Encoding and Content-Length headers are removed for the next handlers.
+// Reading more than the maximum size fails with renderer.ErrBodyTooLarge, answered with 413 by renderer.Bind,
+// so that small compressed bodies cannot expand without bound.
+// Unsupported encodings are answered with 415 and the supported ones listed in the Accept-Encoding header (RFC 7694).
+func DecompressRequest(opts ...DecompressOption) func(next http.Handler) http.Handler {
+	cfg := &decompressConfig{maxSize: DefaultDecompressMaxSize}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			codings := contentCodings(r.Header.Values("Content-Encoding"))
+			if len(codings) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, coding := range codings {
+				if supportedEncodings[coding].decoder == nil {
+					unsupportedEncoding(w, r, coding)
+					return
+				}
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				body, err := newDecompressBody(r.Body, codings, cfg.maxSize)
+				if err != nil {
+					_ = renderer.Problem(w, r, renderer.NewProblem(http.StatusBadRequest, err.Error()))
+					return
+				}
+				defer body.Close()
+				r.Body = body
+			}
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// contentCodings returns the codings of the Content-Encoding header in the order they were applied, identity excluded.
+func contentCodings(values []string) []string {
+	var codings []string
+	for _, value := range values {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "" && coding != "identity" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	return codings
+}
+
+func unsupportedEncoding(w http.ResponseWriter, r *http.Request, coding string) {
+	var accepted []string
+	for _, encoding := range DefaultCompressPreference {
+		if supportedEncodings[encoding].decoder != nil {
+			accepted = append(accepted, encoding)
+		}
+	}
+	w.Header().Set(negotiation.HeaderAcceptEncoding, strings.Join(accepted, ", "))
+	problem := renderer.NewProblem(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported Content-Encoding: %s", coding))
+	problem.With("acceptable", accepted)
+	_ = renderer.Problem(w, r, problem)
+}
+
+// decompressBody reads the body through the decoders of the codings, the last applied coding being decoded first.
+type decompressBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func newDecompressBody(body io.ReadCloser, codings []string, maxSize int64) (*decompressBody, error) {
+	d := &decompressBody{Reader: body, closers: []io.Closer{body}}
+	for i := len(codings) - 1; i >= 0; i-- {
+		decoder, err := supportedEncodings[codings[i]].decoder(d.Reader)
+		if err != nil {
+			_ = d.Close()
+			return nil, errors.Wrapf(err, "invalid %s request body", codings[i])
+		}
+		d.Reader = decoder
+		d.closers = append(d.closers, decoder)
+	}
+	if maxSize > 0 {
+		d.Reader = renderer.MaxBytesReader(d.Reader, maxSize)
+	}
+	return d, nil
+}
+
+// Close closes the decoders then the original body.
+func (d *decompressBody) Close() error {
+	var err error
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		if cerr := d.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	d.closers = nil
+	return err
+}
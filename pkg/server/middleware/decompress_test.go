@@ -0,0 +1,122 @@
+package middleware_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server/middleware"
+	"github.com/athosone/golib/pkg/server/renderer"
+)
+
+func compressBody(encoding string, body []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, _ = zstd.NewWriter(&buf)
+	}
+	_, _ = w.Write(body)
+	Expect(w.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+var _ = Describe("DecompressRequest", func() {
+	var (
+		responseRecorder *httptest.ResponseRecorder
+		received         string
+		receivedHeader   http.Header
+		readErr          error
+		handler          http.Handler
+	)
+
+	BeforeEach(func() {
+		responseRecorder = httptest.NewRecorder()
+		received, receivedHeader, readErr = "", nil, nil
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			received, receivedHeader, readErr = string(body), r.Header, err
+		})
+	})
+
+	serve := func(encoding string, body []byte, opts ...middleware.DecompressOption) {
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		request.Header.Set("Content-Encoding", encoding)
+		middleware.DecompressRequest(opts...)(handler).ServeHTTP(responseRecorder, request)
+	}
+
+	DescribeTable("should decode the supported encodings",
+		func(encoding string) {
+			serve(encoding, compressBody(encoding, []byte(`{"name":"test"}`)))
+			Expect(readErr).To(BeNil())
+			Expect(received).To(Equal(`{"name":"test"}`))
+			Expect(receivedHeader.Get("Content-Encoding")).To(BeEmpty())
+			Expect(receivedHeader.Get("Content-Length")).To(BeEmpty())
+		},
+		Entry("gzip", "gzip"),
+		Entry("deflate", "deflate"),
+		Entry("br", "br"),
+		Entry("zstd", "zstd"),
+	)
+
+	It("should decode the codings in the reverse order of their application", func() {
+		body := compressBody("br", compressBody("gzip", []byte("test")))
+		serve("gzip, br", body)
+		Expect(readErr).To(BeNil())
+		Expect(received).To(Equal("test"))
+	})
+
+	It("should pass identity bodies through", func() {
+		serve("identity", []byte("test"))
+		Expect(received).To(Equal("test"))
+	})
+
+	It("should reject the unsupported encodings", func() {
+		serve("compress", []byte("test"))
+		Expect(responseRecorder.Code).To(Equal(http.StatusUnsupportedMediaType))
+		Expect(responseRecorder.Header().Get("Accept-Encoding")).To(Equal("br, zstd, gzip, deflate"))
+		var problem map[string]any
+		Expect(json.Unmarshal(responseRecorder.Body.Bytes(), &problem)).To(Succeed())
+		Expect(problem["acceptable"]).To(ConsistOf("br", "zstd", "gzip", "deflate"))
+	})
+
+	It("should reject invalid compressed bodies", func() {
+		serve("gzip", []byte("not gzip"))
+		Expect(responseRecorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should limit the size of the decompressed body", func() {
+		serve("gzip", compressBody("gzip", []byte(strings.Repeat("0", 1<<20))), middleware.DecompressMaxSize(1024))
+		Expect(readErr).To(MatchError(renderer.ErrBodyTooLarge))
+		Expect(received).To(HaveLen(1024))
+	})
+
+	It("should answer 413 when binding a body too large", func() {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var v map[string]any
+			_ = renderer.Bind(w, r, &v)
+		})
+		request := httptest.NewRequest(http.MethodPost, "/",
+			bytes.NewReader(compressBody("zstd", []byte(`{"name":"`+strings.Repeat("a", 4096)+`"}`))))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Content-Encoding", "zstd")
+		middleware.DecompressRequest(middleware.DecompressMaxSize(1024))(handler).ServeHTTP(responseRecorder, request)
+		Expect(responseRecorder.Code).To(Equal(http.StatusRequestEntityTooLarge))
+	})
+})
@@ -2,16 +2,41 @@ package middleware
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	glogger "github.com/athosone/golib/pkg/logger"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
+// LogFormat is the naming of the fields of the access logs.
+type LogFormat int
+
+const (
+	// LogFormatDefault logs the fields with the names of the previous versions (method, url, response_status...).
+	LogFormatDefault LogFormat = iota
+	// LogFormatCombined logs the Apache Combined Log Format line as message, without fields.
+	LogFormatCombined
+	// LogFormatECS logs the fields with the Elastic Common Schema names (http.request.method, url.full...).
+	LogFormatECS
+)
+
+const redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are the headers whose values are redacted from the access logs.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
 type responseWrapper struct {
 	http.ResponseWriter
-	status int
+	status  int
+	written int64
 }
 
 func (resp *responseWrapper) WriteHeader(status int) {
@@ -19,48 +44,307 @@ func (resp *responseWrapper) WriteHeader(status int) {
 	resp.ResponseWriter.WriteHeader(status)
 }
 
-func RequestLogger(excludedPath []string) func(next http.Handler) http.Handler {
-	excludedPathMap := make(map[string]struct{}, len(excludedPath))
-	for _, p := range excludedPath {
-		excludedPathMap[p] = struct{}{}
+func (resp *responseWrapper) Write(b []byte) (int, error) {
+	n, err := resp.ResponseWriter.Write(b)
+	resp.written += int64(n)
+	return n, err
+}
+
+func (resp *responseWrapper) Flush() {
+	if f, ok := resp.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped response writer, used by http.ResponseController.
+func (resp *responseWrapper) Unwrap() http.ResponseWriter {
+	return resp.ResponseWriter
+}
+
+// countingBody counts the bytes of the request body read by the handlers.
+type countingBody struct {
+	io.ReadCloser
+	read int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+type logSample struct {
+	// count is updated atomically, it comes first to be 64-bit aligned on 32-bit platforms.
+	count    uint64
+	every    uint64
+	patterns []string
+}
+
+type requestLoggerConfig struct {
+	allowed map[string]bool
+	denied  map[string]bool
+	levels  map[int]zapcore.Level
+	samples []*logSample
+	format  LogFormat
+}
+
+// RequestLoggerOption configures RequestLogger.
+type RequestLoggerOption func(*requestLoggerConfig)
+
+// LogHeaderAllowlist only logs the given request and response headers, every header is logged by default.
+func LogHeaderAllowlist(headers ...string) RequestLoggerOption {
+	return func(c *requestLoggerConfig) {
+		c.allowed = headerSet(headers)
+	}
+}
+
+// LogHeaderDenylist redacts the values of the given headers, replacing DefaultRedactedHeaders.
+func LogHeaderDenylist(headers ...string) RequestLoggerOption {
+	return func(c *requestLoggerConfig) {
+		c.denied = headerSet(headers)
+	}
+}
+
+// LogStatusLevel sets the level of the responses of a status class (1 to 5 for 1xx to 5xx).
+// By default 5xx are logged at Error, 4xx at Warn and the others at Info.
+func LogStatusLevel(class int, level zapcore.Level) RequestLoggerOption {
+	return func(c *requestLoggerConfig) {
+		c.levels[class] = level
+	}
+}
+
+// LogSampling logs one of every n requests whose path matches one of the patterns (see RequestLogger),
+// server errors are always logged.
+func LogSampling(every int, patterns ...string) RequestLoggerOption {
+	return func(c *requestLoggerConfig) {
+		if every > 1 {
+			c.samples = append(c.samples, &logSample{every: uint64(every), patterns: patterns})
+		}
+	}
+}
+
+// LogFieldFormat sets the format of the access logs, defaults to LogFormatDefault.
+func LogFieldFormat(format LogFormat) RequestLoggerOption {
+	return func(c *requestLoggerConfig) {
+		c.format = format
+	}
+}
+
+func headerSet(headers []string) map[string]bool {
+	set := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		set[http.CanonicalHeaderKey(h)] = true
+	}
+	return set
+}
+
+// RequestLogger logs the requests and their responses with the logger of the request context (see InjectLoggerInRequest).
+// The paths matching one of the excluded patterns are not logged. Patterns are path.Match globs (/api/*/health),
+// a trailing "*" matches every path with the prefix (/static/* matches /static/js/app.js).
+// The values of DefaultRedactedHeaders are redacted, see the options to change the logged headers, the levels,
+// sample high-volume paths or change the field names.
+func RequestLogger(excludedPath []string, opts ...RequestLoggerOption) func(next http.Handler) http.Handler {
+	cfg := &requestLoggerConfig{
+		denied: headerSet(DefaultRedactedHeaders),
+		levels: map[int]zapcore.Level{
+			4: zapcore.WarnLevel,
+			5: zapcore.ErrorLevel,
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg)
 	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			logger := glogger.LoggerFromContextOrDefault(r.Context())
 			requestTime := time.Now()
-			rw := &responseWrapper{w, http.StatusOK}
+			rw := &responseWrapper{ResponseWriter: w, status: http.StatusOK}
+			var body *countingBody
+			if r.Body != nil && r.Body != http.NoBody {
+				body = &countingBody{ReadCloser: r.Body}
+				r.Body = body
+			}
 			next.ServeHTTP(rw, r)
 
-			if logger == nil {
+			if logger == nil || matchPaths(excludedPath, r.URL.Path) || !cfg.sampled(r.URL.Path, rw.status) {
 				return
 			}
-			if _, ok := excludedPathMap[r.URL.Path]; ok {
-				return
+			requestSize := r.ContentLength
+			if body != nil && body.read > requestSize {
+				requestSize = body.read
+			}
+			if requestSize < 0 {
+				requestSize = 0
+			}
+			entry := accessLog{
+				request:     r,
+				response:    rw,
+				requestSize: requestSize,
+				elapsed:     time.Since(requestTime),
+				start:       requestTime,
+			}
+			msg, fields := cfg.fields(&entry)
+			switch cfg.level(rw.status) {
+			case zapcore.DebugLevel:
+				logger.Debugw(msg, fields...)
+			case zapcore.InfoLevel:
+				logger.Infow(msg, fields...)
+			case zapcore.WarnLevel:
+				logger.Warnw(msg, fields...)
+			default:
+				logger.Errorw(msg, fields...)
 			}
-			logger.Infow("",
-				"method", r.Method,
-				"url", fmt.Sprintf("%s%s", r.Host, r.URL.String()),
-				"protocol", r.Proto,
-				"remote_addr", r.RemoteAddr,
-				"user_agent", r.UserAgent(),
-				"elapsed_time", time.Since(requestTime).String(),
-				"request_headers", r.Header,
-				"response_headers", rw.Header(),
-				"response_status", rw.status,
-			)
 		})
 	}
 }
 
+// matchPaths is true when the path matches one of the patterns.
+func matchPaths(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if pattern == p {
+			return true
+		}
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern && !strings.ContainsAny(prefix, `*?[\`) {
+			if strings.HasPrefix(p, prefix) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *requestLoggerConfig) sampled(p string, status int) bool {
+	if status >= http.StatusInternalServerError {
+		return true
+	}
+	for _, s := range cfg.samples {
+		if matchPaths(s.patterns, p) {
+			return (atomic.AddUint64(&s.count, 1)-1)%s.every == 0
+		}
+	}
+	return true
+}
+
+func (cfg *requestLoggerConfig) level(status int) zapcore.Level {
+	if level, ok := cfg.levels[status/100]; ok {
+		return level
+	}
+	return zapcore.InfoLevel
+}
+
+// headers returns a copy of the headers logged, with the denied values redacted.
+func (cfg *requestLoggerConfig) headers(h http.Header) http.Header {
+	logged := make(http.Header, len(h))
+	for key, values := range h {
+		key = http.CanonicalHeaderKey(key)
+		if cfg.allowed != nil && !cfg.allowed[key] {
+			continue
+		}
+		if cfg.denied[key] {
+			values = []string{redacted}
+		}
+		logged[key] = values
+	}
+	return logged
+}
+
+type accessLog struct {
+	request     *http.Request
+	response    *responseWrapper
+	requestSize int64
+	elapsed     time.Duration
+	start       time.Time
+}
+
+func (cfg *requestLoggerConfig) fields(e *accessLog) (string, []any) {
+	r, rw := e.request, e.response
+	switch cfg.format {
+	case LogFormatCombined:
+		return combinedLine(e), nil
+	case LogFormatECS:
+		return fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, rw.status), []any{
+			"http.request.method", r.Method,
+			"url.full", fmt.Sprintf("%s%s", r.Host, r.URL.String()),
+			"url.path", r.URL.Path,
+			"http.version", strings.TrimPrefix(r.Proto, "HTTP/"),
+			"client.address", r.RemoteAddr,
+			"user_agent.original", r.UserAgent(),
+			"event.duration", e.elapsed.Nanoseconds(),
+			"http.request.headers", cfg.headers(r.Header),
+			"http.request.body.bytes", e.requestSize,
+			"http.response.headers", cfg.headers(rw.Header()),
+			"http.response.status_code", rw.status,
+			"http.response.body.bytes", rw.written,
+		}
+	default:
+		return fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, rw.status), []any{
+			"method", r.Method,
+			"url", fmt.Sprintf("%s%s", r.Host, r.URL.String()),
+			"protocol", r.Proto,
+			"remote_addr", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+			"elapsed_time", e.elapsed.String(),
+			"request_headers", cfg.headers(r.Header),
+			"request_size", e.requestSize,
+			"response_headers", cfg.headers(rw.Header()),
+			"response_status", rw.status,
+			"response_size", rw.written,
+		}
+	}
+}
+
+// combinedLine formats the Apache Combined Log Format line:
+// %h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+func combinedLine(e *accessLog) string {
+	r := e.request
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+	size := "-"
+	if e.response.written > 0 {
+		size = strconv.FormatInt(e.response.written, 10)
+	}
+	return fmt.Sprintf("%s - %s [%s] %q %d %s %q %q",
+		dashIfEmpty(host),
+		user,
+		e.start.Format("02/Jan/2006:15:04:05 -0700"),
+		fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), r.Proto),
+		e.response.status,
+		size,
+		dashIfEmpty(r.Referer()),
+		dashIfEmpty(r.UserAgent()),
+	)
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
 type LoggerFactory func(*http.Request) *zap.SugaredLogger
 
 // InjectLoggerInRequest injects a logger into the request context
 // The logger can then be retrieved from the request context using the logger.LoggerFromContextOrDefault(context.Context) function in the logger package.
 // The logger factory param is used to create a logger for each request. In the factory you could specify which values you want to pass to subsequent middleware/controllers.
+// The request id and the trace context stored by Correlation are added to the logger.
 func InjectLoggerInRequest(logFactory LoggerFactory) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			l := logFactory(r)
+			if fields := correlationFields(r.Context()); len(fields) > 0 {
+				l = l.With(fields...)
+			}
 			ctx := glogger.NewContextWithLogger(r.Context(), l)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -0,0 +1,135 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/athosone/golib/pkg/server/middleware"
+)
+
+var _ = Describe("RequestLogger", func() {
+	var (
+		core   zapcore.Core
+		logs   *observer.ObservedLogs
+		status int
+	)
+
+	BeforeEach(func() {
+		core, logs = observer.New(zapcore.DebugLevel)
+		status = http.StatusOK
+	})
+
+	serve := func(target string, excluded []string, opts ...middleware.RequestLoggerOption) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.ReadAll(r.Body)
+			w.Header().Set("Set-Cookie", "session=secret")
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("hello"))
+		})
+		logger := zap.New(core).Sugar()
+		h := middleware.InjectLoggerInRequest(func(*http.Request) *zap.SugaredLogger { return logger })(
+			middleware.RequestLogger(excluded, opts...)(handler))
+
+		request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"name":"test"}`))
+		request.Header.Set("Authorization", "Bearer token")
+		request.Header.Set("User-Agent", "ginkgo")
+		request.Header.Set("Referer", "http://example.com/")
+		request.Header.Set("X-Request-Id", "42")
+		h.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	It("should log the request with the sizes and redacted headers", func() {
+		serve("/books?page=2", nil)
+		Expect(logs.Len()).To(Equal(1))
+		entry := logs.All()[0]
+		Expect(entry.Level).To(Equal(zapcore.InfoLevel))
+		Expect(entry.Message).To(Equal("POST /books 200"))
+		fields := entry.ContextMap()
+		Expect(fields["method"]).To(Equal(http.MethodPost))
+		Expect(fields["response_status"]).To(BeEquivalentTo(http.StatusOK))
+		Expect(fields["response_size"]).To(BeEquivalentTo(5))
+		Expect(fields["request_size"]).To(BeEquivalentTo(15))
+		Expect(fields["request_headers"]).To(HaveKeyWithValue("Authorization", []string{"[REDACTED]"}))
+		Expect(fields["request_headers"]).To(HaveKeyWithValue("X-Request-Id", []string{"42"}))
+		Expect(fields["response_headers"]).To(HaveKeyWithValue("Set-Cookie", []string{"[REDACTED]"}))
+	})
+
+	It("should only log the allowed headers", func() {
+		serve("/books", nil, middleware.LogHeaderAllowlist("x-request-id", "Authorization"), middleware.LogHeaderDenylist("X-Request-Id"))
+		fields := logs.All()[0].ContextMap()
+		Expect(fields["request_headers"]).To(Equal(http.Header{
+			"Authorization": {"Bearer token"},
+			"X-Request-Id":  {"[REDACTED]"},
+		}))
+		Expect(fields["response_headers"]).To(BeEmpty())
+	})
+
+	DescribeTable("should map the status class to a level",
+		func(code int, level zapcore.Level, opts ...middleware.RequestLoggerOption) {
+			status = code
+			serve("/books", nil, opts...)
+			Expect(logs.All()[0].Level).To(Equal(level))
+		},
+		Entry("2xx", http.StatusCreated, zapcore.InfoLevel),
+		Entry("4xx", http.StatusNotFound, zapcore.WarnLevel),
+		Entry("5xx", http.StatusBadGateway, zapcore.ErrorLevel),
+		Entry("configured", http.StatusNotFound, zapcore.DebugLevel, middleware.LogStatusLevel(4, zapcore.DebugLevel)),
+	)
+
+	It("should exclude the paths matching the patterns", func() {
+		excluded := []string{"/healthy", "/static/*", "/api/*/ready"}
+		for _, p := range []string{"/healthy", "/static/js/app.js", "/api/v1/ready", "/healthy/deep", "/api/v1/books"} {
+			serve(p, excluded)
+		}
+		Expect(logs.Len()).To(Equal(2))
+		Expect(logs.All()[0].Message).To(Equal("POST /healthy/deep 200"))
+		Expect(logs.All()[1].Message).To(Equal("POST /api/v1/books 200"))
+	})
+
+	It("should sample the high-volume paths", func() {
+		logger := zap.New(core).Sugar()
+		h := middleware.InjectLoggerInRequest(func(*http.Request) *zap.SugaredLogger { return logger })(
+			middleware.RequestLogger(nil, middleware.LogSampling(5, "/metrics"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("fail") != "" {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			})))
+		for i := 0; i < 10; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		}
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics?fail=1", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books", nil))
+		// 2 of the 10 sampled requests, the server error and the request of another path
+		Expect(logs.Len()).To(Equal(4))
+	})
+
+	It("should log the Apache Combined Log Format", func() {
+		serve("/books?page=2", nil, middleware.LogFieldFormat(middleware.LogFormatCombined))
+		entry := logs.All()[0]
+		Expect(entry.Context).To(BeEmpty())
+		Expect(entry.Message).To(MatchRegexp(
+			`^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /books\?page=2 HTTP/1\.1" 200 5 "http://example.com/" "ginkgo"$`))
+	})
+
+	It("should log the Elastic Common Schema fields", func() {
+		serve("/books", nil, middleware.LogFieldFormat(middleware.LogFormatECS))
+		fields := logs.All()[0].ContextMap()
+		Expect(fields).To(HaveKeyWithValue("http.request.method", http.MethodPost))
+		Expect(fields).To(HaveKeyWithValue("url.path", "/books"))
+		Expect(fields).To(HaveKeyWithValue("http.version", "1.1"))
+		Expect(fields).To(HaveKeyWithValue("user_agent.original", "ginkgo"))
+		Expect(fields["http.response.status_code"]).To(BeEquivalentTo(http.StatusOK))
+		Expect(fields["http.response.body.bytes"]).To(BeEquivalentTo(5))
+		Expect(fields["http.request.body.bytes"]).To(BeEquivalentTo(15))
+		Expect(fields).To(HaveKey("event.duration"))
+	})
+})
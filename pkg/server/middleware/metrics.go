@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/athosone/golib/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/unit"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// RequestMetrics records the RED metrics of the requests with OpenTelemetry, see telemetry.New for the providers:
+//   - http.server.requests: number of requests (rate)
+//   - http.server.errors: number of responses with a 5xx status (errors)
+//   - http.server.duration: duration of the requests in milliseconds (duration)
+//   - http.server.active_requests: number of requests being served
+//
+// Measures are attributed with the method and the status code. The excluded paths are not measured,
+// they follow the patterns of RequestLogger.
+func RequestMetrics(excludedPath []string, opts ...telemetry.Option) func(next http.Handler) http.Handler {
+	t := telemetry.New(opts...)
+	requests := t.Int64Counter("http.server.requests", "Number of HTTP requests", unit.Dimensionless)
+	errs := t.Int64Counter("http.server.errors", "Number of HTTP responses with a 5xx status", unit.Dimensionless)
+	duration := t.Float64Histogram("http.server.duration", "Duration of the HTTP requests", unit.Milliseconds)
+	active := t.Int64UpDownCounter("http.server.active_requests", "Number of HTTP requests being served", unit.Dimensionless)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if matchPaths(excludedPath, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			method := semconv.HTTPMethodKey.String(r.Method)
+			active.Add(ctx, 1, method)
+			defer active.Add(ctx, -1, method)
+
+			start := time.Now()
+			rw := &responseWrapper{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			attrs := []attribute.KeyValue{method, semconv.HTTPStatusCodeKey.Int(rw.status)}
+			requests.Add(ctx, 1, attrs...)
+			if rw.status >= http.StatusInternalServerError {
+				errs.Add(ctx, 1, attrs...)
+			}
+			duration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), attrs...)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/athosone/golib/pkg/server/middleware"
+	"github.com/athosone/golib/pkg/telemetry"
+)
+
+// collect returns the metrics recorded by the reader by name.
+func collect(reader sdkmetric.Reader) map[string]metricdata.Aggregation {
+	rm, err := reader.Collect(context.Background())
+	Expect(err).To(BeNil())
+	metrics := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+func sumOf(data metricdata.Aggregation) int64 {
+	var total int64
+	for _, dp := range data.(metricdata.Sum[int64]).DataPoints {
+		total += dp.Value
+	}
+	return total
+}
+
+var _ = Describe("RequestMetrics", func() {
+	It("should record the rate, the errors and the duration of the requests", func() {
+		reader := sdkmetric.NewManualReader()
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		var activeDuringRequest int64
+		handler := middleware.RequestMetrics([]string{"/healthy"}, telemetry.WithMeterProvider(mp))(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/books" {
+					activeDuringRequest = sumOf(collect(reader)["http.server.active_requests"])
+				}
+				if r.URL.Path == "/fail" {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+
+		for _, path := range []string{"/books", "/books", "/fail", "/healthy"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		metrics := collect(reader)
+		Expect(activeDuringRequest).To(BeEquivalentTo(1))
+		Expect(sumOf(metrics["http.server.active_requests"])).To(BeEquivalentTo(0))
+		Expect(sumOf(metrics["http.server.requests"])).To(BeEquivalentTo(3))
+		Expect(sumOf(metrics["http.server.errors"])).To(BeEquivalentTo(1))
+		requests := metrics["http.server.requests"].(metricdata.Sum[int64]).DataPoints
+		Expect(requests).To(HaveLen(2))
+		var count uint64
+		for _, dp := range metrics["http.server.duration"].(metricdata.Histogram).DataPoints {
+			count += dp.Count
+		}
+		Expect(count).To(BeEquivalentTo(3))
+	})
+})
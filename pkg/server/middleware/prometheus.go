@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/athosone/golib/pkg/server"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute is the route label of the requests not dispatched to a route, e.g. 404.
+const unmatchedRoute = "unmatched"
+
+// DefaultSizeBuckets are the buckets of the response size histogram, from 100B to 10MB.
+var DefaultSizeBuckets = prometheus.ExponentialBuckets(100, 10, 6)
+
+type prometheusConfig struct {
+	registerer   prometheus.Registerer
+	namespace    string
+	buckets      []float64
+	sizeBuckets  []float64
+	versionParam string
+}
+
+// PrometheusOption configures Prometheus.
+type PrometheusOption func(*prometheusConfig)
+
+// PrometheusRegisterer sets the registry of the metrics, defaults to prometheus.DefaultRegisterer.
+func PrometheusRegisterer(registerer prometheus.Registerer) PrometheusOption {
+	return func(c *prometheusConfig) {
+		c.registerer = registerer
+	}
+}
+
+// PrometheusNamespace prefixes the names of the metrics.
+func PrometheusNamespace(namespace string) PrometheusOption {
+	return func(c *prometheusConfig) {
+		c.namespace = namespace
+	}
+}
+
+// PrometheusBuckets sets the buckets of the latency histogram in seconds, defaults to prometheus.DefBuckets.
+func PrometheusBuckets(buckets ...float64) PrometheusOption {
+	return func(c *prometheusConfig) {
+		c.buckets = buckets
+	}
+}
+
+// PrometheusSizeBuckets sets the buckets of the response size histogram in bytes, defaults to DefaultSizeBuckets.
+func PrometheusSizeBuckets(buckets ...float64) PrometheusOption {
+	return func(c *prometheusConfig) {
+		c.sizeBuckets = buckets
+	}
+}
+
+// PrometheusVersionParam sets the media type parameter holding the version, defaults to "v" as renderer.Versions.
+func PrometheusVersionParam(name string) PrometheusOption {
+	return func(c *prometheusConfig) {
+		c.versionParam = name
+	}
+}
+
+// Prometheus measures the requests with Prometheus metrics labelled by method, status class (2xx),
+// route pattern and version of the negotiated media type:
+//   - http_requests_total: number of requests
+//   - http_request_duration_seconds: latency of the requests
+//   - http_response_size_bytes: size of the response bodies
+//
+// The route and the version are reported by routing.GRouter (see server.RouteInfo), "unmatched" and ""
+// are used for the requests not dispatched to a route. The version is read from the media types registered
+// by the route, and the methods outside the standard ones are labelled "other", to bound the label values. Expose the metrics with MetricsHandler.
+// The excluded paths follow the patterns of RequestLogger.
+// Metrics already registered by another Prometheus middleware are reused.
+func Prometheus(excludedPath []string, opts ...PrometheusOption) func(next http.Handler) http.Handler {
+	cfg := &prometheusConfig{
+		registerer:   prometheus.DefaultRegisterer,
+		buckets:      prometheus.DefBuckets,
+		sizeBuckets:  DefaultSizeBuckets,
+		versionParam: "v",
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	labels := []string{"method", "status", "route", "version"}
+	requests := register(cfg.registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: cfg.namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests.",
+	}, labels))
+	latency := register(cfg.registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: cfg.namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests.",
+		Buckets:   cfg.buckets,
+	}, labels))
+	sizes := register(cfg.registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: cfg.namespace,
+		Name:      "http_response_size_bytes",
+		Help:      "Size of the HTTP response bodies.",
+		Buckets:   cfg.sizeBuckets,
+	}, labels))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if matchPaths(excludedPath, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, info := server.NewRouteInfoContext(r.Context())
+			start := time.Now()
+			rw := &responseWrapper{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			route := info.Pattern
+			if route == "" {
+				route = unmatchedRoute
+			}
+			values := []string{methodLabel(r.Method), statusClass(rw.status), route, mediaTypeVersion(info.MediaType, cfg.versionParam)}
+			requests.WithLabelValues(values...).Inc()
+			latency.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+			sizes.WithLabelValues(values...).Observe(float64(rw.written))
+		})
+	}
+}
+
+// register registers the collector, or returns the one already registered with the same description.
+func register[C prometheus.Collector](registerer prometheus.Registerer, collector C) C {
+	if err := registerer.Register(collector); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(errors.Wrap(err, "failed to register the Prometheus metrics"))
+	}
+	return collector
+}
+
+// methodLabel returns the method, or "other" for the methods outside RFC 9110 and PATCH,
+// so that clients cannot create series with arbitrary methods.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+func mediaTypeVersion(mediaType, param string) string {
+	if mediaType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return ""
+	}
+	return params[param]
+}
+
+// MetricsHandler exposes the metrics of the gatherer in the Prometheus exposition format,
+// defaults to prometheus.DefaultGatherer when nil.
+func MetricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
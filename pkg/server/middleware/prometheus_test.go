@@ -0,0 +1,134 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/athosone/golib/pkg/server/middleware"
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+// gather returns the metric families of the registry by name.
+func gather(registry *prometheus.Registry) map[string]*dto.MetricFamily {
+	families, err := registry.Gather()
+	Expect(err).To(BeNil())
+	metrics := map[string]*dto.MetricFamily{}
+	for _, mf := range families {
+		metrics[mf.GetName()] = mf
+	}
+	return metrics
+}
+
+func labelsOf(m *dto.Metric) map[string]string {
+	labels := map[string]string{}
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	return labels
+}
+
+var _ = Describe("Prometheus", func() {
+	var (
+		registry *prometheus.Registry
+		handler  http.Handler
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+		router := routing.NewRouter()
+		router.Route("/books/{id}", func(books *routing.GRouter) {
+			books.Get(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("book"))
+			}).Produce("application/vnd.athosone.book+json; v=v1", "application/vnd.athosone.book+json; v=v2").SetDefault()
+		})
+		handler = middleware.Prometheus([]string{"/healthy"}, middleware.PrometheusRegisterer(registry))(router)
+	})
+
+	serve := func(path, accept string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	It("should label the requests with the route and the negotiated version", func() {
+		serve("/books/1", "application/vnd.athosone.book+json; v=v2")
+		serve("/books/2", "application/vnd.athosone.book+json; v=v2")
+		serve("/books/3", "application/vnd.athosone.book+json; v=v1")
+		serve("/authors/1", "")
+		serve("/healthy", "")
+
+		metrics := gather(registry)
+		requests := metrics["http_requests_total"].GetMetric()
+		Expect(requests).To(HaveLen(3))
+		counts := map[string]float64{}
+		for _, m := range requests {
+			labels := labelsOf(m)
+			Expect(labels).To(HaveKeyWithValue("method", "GET"))
+			counts[labels["status"]+" "+labels["route"]+" "+labels["version"]] = m.GetCounter().GetValue()
+		}
+		Expect(counts).To(Equal(map[string]float64{
+			"2xx /books/{id} v2": 2,
+			"2xx /books/{id} v1": 1,
+			"4xx unmatched ":     1,
+		}))
+
+		for _, m := range metrics["http_response_size_bytes"].GetMetric() {
+			if labelsOf(m)["version"] == "v2" {
+				Expect(m.GetHistogram().GetSampleCount()).To(BeEquivalentTo(2))
+				Expect(m.GetHistogram().GetSampleSum()).To(BeEquivalentTo(8))
+			}
+		}
+		Expect(metrics["http_request_duration_seconds"].GetMetric()).To(HaveLen(3))
+	})
+
+	It("should bound the values of the labels sent by the clients", func() {
+		router := routing.NewRouter()
+		router.Get(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}).Produce("application/json").SetDefault()
+		handler = middleware.Prometheus(nil, middleware.PrometheusRegisterer(registry))(router)
+		for _, method := range []string{"GET", "PROPFIND", "X-RANDOM"} {
+			req := httptest.NewRequest(method, "/", nil)
+			req.Header.Set("Accept", "application/json; v="+method)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		requests := gather(registry)["http_requests_total"].GetMetric()
+		Expect(requests).To(HaveLen(2))
+		counts := map[string]float64{}
+		for _, m := range requests {
+			labels := labelsOf(m)
+			Expect(labels).To(HaveKeyWithValue("version", ""))
+			counts[labels["method"]+" "+labels["status"]] = m.GetCounter().GetValue()
+		}
+		Expect(counts).To(Equal(map[string]float64{"GET 2xx": 1, "other 4xx": 2}))
+	})
+
+	It("should reuse the metrics already registered", func() {
+		other := middleware.Prometheus(nil, middleware.PrometheusRegisterer(registry))(http.NotFoundHandler())
+		other.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		serve("/missing", "")
+
+		requests := gather(registry)["http_requests_total"].GetMetric()
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].GetCounter().GetValue()).To(BeEquivalentTo(2))
+	})
+
+	It("should expose the metrics", func() {
+		serve("/books/1", "application/vnd.athosone.book+json; v=v1")
+		recorder := httptest.NewRecorder()
+		middleware.MetricsHandler(registry).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		body, _ := io.ReadAll(recorder.Body)
+		Expect(string(body)).To(ContainSubstring(`http_requests_total{method="GET",route="/books/{id}",status="2xx",version="v1"} 1`))
+	})
+})
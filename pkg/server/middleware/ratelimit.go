@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	glogger "github.com/athosone/golib/pkg/logger"
+	"github.com/athosone/golib/pkg/server/renderer"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// RateLimitResult is the decision of a Limiter for a request.
+type RateLimitResult struct {
+	Allowed bool
+	// Limit is the number of requests allowed per period.
+	Limit int
+	// Remaining is the number of requests left.
+	Remaining int
+	// Reset is the time left until the quota is fully available again.
+	Reset time.Duration
+	// RetryAfter is the time left until a request is allowed, zero when allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter limits the rate of the requests of each key.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+type tokenBucket struct {
+	limit  int
+	period time.Duration
+	store  RateLimitStore
+}
+
+// NewTokenBucket creates a token bucket Limiter allowing bursts of limit requests, refilled by limit tokens per period.
+// It panics when limit or period is not positive.
+func NewTokenBucket(limit int, period time.Duration, store RateLimitStore) Limiter {
+	if limit <= 0 || period <= 0 {
+		panic(fmt.Sprintf("NewTokenBucket: limit and period must be positive, got %d and %s", limit, period))
+	}
+	return &tokenBucket{limit: limit, period: period, store: store}
+}
+
+func (tb *tokenBucket) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	result := RateLimitResult{Limit: tb.limit}
+	// tokens refilled per nanosecond
+	rate := float64(tb.limit) / float64(tb.period)
+	err := tb.store.Update(ctx, key, tb.period, func(state *RateLimitState) {
+		now := time.Now()
+		tokens := float64(tb.limit)
+		if !state.Time.IsZero() {
+			tokens = math.Min(tokens, state.Value+float64(now.Sub(state.Time))*rate)
+		}
+		if tokens >= 1 {
+			tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = time.Duration((1 - tokens) / rate)
+		}
+		state.Value, state.Time = tokens, now
+		result.Remaining = int(tokens)
+		result.Reset = time.Duration((float64(tb.limit) - tokens) / rate)
+	})
+	return result, err
+}
+
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+	store  RateLimitStore
+}
+
+// NewSlidingWindow creates a sliding window Limiter allowing limit requests per window.
+// The count of the sliding window is approximated from the counts of the current and the previous fixed windows.
+// It panics when limit or window is not positive.
+func NewSlidingWindow(limit int, window time.Duration, store RateLimitStore) Limiter {
+	if limit <= 0 || window <= 0 {
+		panic(fmt.Sprintf("NewSlidingWindow: limit and window must be positive, got %d and %s", limit, window))
+	}
+	return &slidingWindow{limit: limit, window: window, store: store}
+}
+
+func (sw *slidingWindow) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	result := RateLimitResult{Limit: sw.limit}
+	err := sw.store.Update(ctx, key, 2*sw.window, func(state *RateLimitState) {
+		now := time.Now()
+		start := now.Truncate(sw.window)
+		if !state.Time.Equal(start) {
+			if state.Time.Equal(start.Add(-sw.window)) {
+				state.Previous = state.Value
+			} else {
+				state.Previous = 0
+			}
+			state.Value, state.Time = 0, start
+		}
+		elapsed := float64(now.Sub(start)) / float64(sw.window)
+		count := state.Previous*(1-elapsed) + state.Value
+		if count+1 <= float64(sw.limit) {
+			state.Value++
+			count++
+			result.Allowed = true
+		} else {
+			result.RetryAfter = sw.retryAfter(state, now)
+		}
+		result.Remaining = int(math.Max(0, float64(sw.limit)-count))
+		result.Reset = start.Add(sw.window).Sub(now)
+		if state.Value > 0 {
+			// the requests of the current window slide out during the next one
+			result.Reset += sw.window
+		}
+	})
+	return result, err
+}
+
+// retryAfter returns the time left until the count of the sliding window drops below the limit.
+func (sw *slidingWindow) retryAfter(state *RateLimitState, now time.Time) time.Duration {
+	free := float64(sw.limit - 1)
+	start, previous, current := state.Time, state.Previous, state.Value
+	if current > free {
+		// the current window becomes the previous one
+		start, previous, current = start.Add(sw.window), current, 0
+	}
+	at := start
+	if previous > 0 {
+		at = start.Add(time.Duration((1 - (free-current)/previous) * float64(sw.window)))
+	}
+	return at.Sub(now)
+}
+
+// RateLimitKeyFunc returns the key the requests are limited by, requests with an empty key are not limited.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// KeyByIP limits the requests by client IP, read from http.Request.RemoteAddr.
+// Set RemoteAddr from the forwarding headers before when running behind a proxy.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyBySubject limits the requests by the authenticated subject returned by subject,
+// the requests without subject are limited by IP.
+func KeyBySubject(subject func(ctx context.Context) string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if s := subject(r.Context()); s != "" {
+			return "sub:" + s
+		}
+		return "ip:" + KeyByIP(r)
+	}
+}
+
+type rateLimitConfig struct {
+	key RateLimitKeyFunc
+}
+
+// RateLimitOption configures RateLimit.
+type RateLimitOption func(*rateLimitConfig)
+
+// RateLimitKey sets the key the requests are limited by, defaults to KeyByIP.
+func RateLimitKey(key RateLimitKeyFunc) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.key = key
+	}
+}
+
+// RateLimit limits the rate of the requests per key with the limiter.
+// The quota is sent in the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+// (https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/).
+// Requests over the limit are answered with a 429 problem and the Retry-After header.
+// When the store fails, the error is logged and the request is served.
+func RateLimit(limiter Limiter, opts ...RateLimitOption) func(next http.Handler) http.Handler {
+	cfg := &rateLimitConfig{key: KeyByIP}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := cfg.key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			result, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				glogger.LoggerFromContextOrDefault(r.Context()).Errorw("rate limit store failure", "error", err.Error())
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+			h.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			h.Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.Reset)))
+			if !result.Allowed {
+				retryAfter := ceilSeconds(result.RetryAfter)
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				h.Set(HeaderRetryAfter, strconv.Itoa(retryAfter))
+				_ = renderer.Problem(w, r, renderer.NewProblem(http.StatusTooManyRequests, "rate limit exceeded"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// RateLimitState is the state of a Limiter for a key.
+type RateLimitState struct {
+	// Value is the number of tokens left in the bucket, or the count of the current window.
+	Value float64
+	// Previous is the count of the previous window.
+	Previous float64
+	// Time is the last update of the bucket, or the start of the current window.
+	Time time.Time
+}
+
+// RateLimitStore holds the states of the limiters by key.
+// Implement it to share the limits between instances, e.g. with optimistic transactions on Redis.
+type RateLimitStore interface {
+	// Update atomically applies fn to the state of the key, the zero state for unknown keys.
+	// The state can be evicted when it has not been updated for ttl.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state *RateLimitState)) error
+}
+
+// DefaultRateLimitShards is the number of shards of the memory store.
+const DefaultRateLimitShards = 32
+
+// sweepEvery is the number of updates of a shard between the evictions of its expired states.
+const sweepEvery = 1024
+
+type memoryEntry struct {
+	state   RateLimitState
+	expires time.Time
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	updates int
+}
+
+type memoryRateLimitStore struct {
+	shards []*memoryShard
+}
+
+// NewMemoryRateLimitStore creates a RateLimitStore keeping the states in memory,
+// split in shards to reduce the lock contention. shards <= 0 uses DefaultRateLimitShards.
+func NewMemoryRateLimitStore(shards int) RateLimitStore {
+	if shards <= 0 {
+		shards = DefaultRateLimitShards
+	}
+	s := &memoryRateLimitStore{shards: make([]*memoryShard, shards)}
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{entries: map[string]*memoryEntry{}}
+	}
+	return s
+}
+
+func (s *memoryRateLimitStore) Update(_ context.Context, key string, ttl time.Duration, fn func(state *RateLimitState)) error {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	shard := s.shards[h.Sum32()%uint32(len(s.shards))]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	now := time.Now()
+	shard.updates++
+	if shard.updates%sweepEvery == 0 {
+		for k, e := range shard.entries {
+			if now.After(e.expires) {
+				delete(shard.entries, k)
+			}
+		}
+	}
+	e, ok := shard.entries[key]
+	if !ok || now.After(e.expires) {
+		e = &memoryEntry{}
+		shard.entries[key] = e
+	}
+	fn(&e.state)
+	e.expires = now.Add(ttl)
+	return nil
+}
@@ -0,0 +1,168 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"github.com/athosone/golib/pkg/server/middleware"
+)
+
+type failingStore struct{}
+
+func (failingStore) Update(context.Context, string, time.Duration, func(*middleware.RateLimitState)) error {
+	return errors.New("store unavailable")
+}
+
+var _ = Describe("RateLimit", func() {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(handler http.Handler, remoteAddr, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		req.RemoteAddr = remoteAddr
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	DescribeTable("should limit the requests per key",
+		func(newLimiter func(store middleware.RateLimitStore) middleware.Limiter) {
+			handler := middleware.RateLimit(newLimiter(middleware.NewMemoryRateLimitStore(0)))(ok)
+			for i := 2; i >= 0; i-- {
+				recorder := serve(handler, "10.0.0.1:1234", "")
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Header().Get("RateLimit-Limit")).To(Equal("3"))
+				Expect(recorder.Header().Get("RateLimit-Remaining")).To(Equal(strconv.Itoa(i)))
+				Expect(recorder.Header().Get("Retry-After")).To(BeEmpty())
+			}
+
+			recorder := serve(handler, "10.0.0.1:5678", "application/xml")
+			Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+xml"))
+			Expect(recorder.Header().Get("RateLimit-Remaining")).To(Equal("0"))
+			retryAfter, _ := strconv.Atoi(recorder.Header().Get("Retry-After"))
+			Expect(retryAfter).To(BeNumerically(">", 0))
+			Expect(retryAfter).To(BeNumerically("<=", 7200))
+			reset, _ := strconv.Atoi(recorder.Header().Get("RateLimit-Reset"))
+			Expect(reset).To(BeNumerically(">=", retryAfter))
+
+			By("limiting the other clients separately")
+			Expect(serve(handler, "10.0.0.2:1234", "").Code).To(Equal(http.StatusOK))
+		},
+		Entry("token bucket", func(store middleware.RateLimitStore) middleware.Limiter {
+			return middleware.NewTokenBucket(3, time.Hour, store)
+		}),
+		Entry("sliding window", func(store middleware.RateLimitStore) middleware.Limiter {
+			return middleware.NewSlidingWindow(3, time.Hour, store)
+		}),
+	)
+
+	It("should refill the token bucket", func() {
+		limiter := middleware.NewTokenBucket(2, 200*time.Millisecond, middleware.NewMemoryRateLimitStore(1))
+		ctx := context.Background()
+		for i := 0; i < 2; i++ {
+			result, err := limiter.Allow(ctx, "key")
+			Expect(err).To(BeNil())
+			Expect(result.Allowed).To(BeTrue())
+		}
+		result, _ := limiter.Allow(ctx, "key")
+		Expect(result.Allowed).To(BeFalse())
+		Expect(result.RetryAfter).To(BeNumerically("~", 100*time.Millisecond, 20*time.Millisecond))
+
+		time.Sleep(result.RetryAfter + 10*time.Millisecond)
+		result, _ = limiter.Allow(ctx, "key")
+		Expect(result.Allowed).To(BeTrue())
+	})
+
+	It("should slide the window", func() {
+		window := 200 * time.Millisecond
+		limiter := middleware.NewSlidingWindow(2, window, middleware.NewMemoryRateLimitStore(1))
+		ctx := context.Background()
+		// start at the beginning of a window
+		time.Sleep(time.Until(time.Now().Truncate(window).Add(window)))
+		for i := 0; i < 2; i++ {
+			result, _ := limiter.Allow(ctx, "key")
+			Expect(result.Allowed).To(BeTrue())
+		}
+		result, _ := limiter.Allow(ctx, "key")
+		Expect(result.Allowed).To(BeFalse())
+		Expect(result.RetryAfter).To(BeNumerically(">", window))
+		Expect(result.RetryAfter).To(BeNumerically("<=", 2*window))
+
+		time.Sleep(result.RetryAfter + 10*time.Millisecond)
+		result, _ = limiter.Allow(ctx, "key")
+		Expect(result.Allowed).To(BeTrue())
+	})
+
+	It("should reject the limits that are not positive", func() {
+		store := middleware.NewMemoryRateLimitStore(1)
+		Expect(func() { middleware.NewTokenBucket(0, time.Hour, store) }).To(Panic())
+		Expect(func() { middleware.NewTokenBucket(1, 0, store) }).To(Panic())
+		Expect(func() { middleware.NewSlidingWindow(-1, time.Hour, store) }).To(Panic())
+		Expect(func() { middleware.NewSlidingWindow(1, 0, store) }).To(Panic())
+	})
+
+	It("should be safe for concurrent use", func() {
+		limiter := middleware.NewTokenBucket(50, time.Hour, middleware.NewMemoryRateLimitStore(4))
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+		)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, _ := limiter.Allow(context.Background(), "key")
+				if result.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		Expect(allowed).To(Equal(50))
+	})
+
+	It("should limit by subject and fall back to the IP", func() {
+		type subjectKey struct{}
+		key := middleware.KeyBySubject(func(ctx context.Context) string {
+			s, _ := ctx.Value(subjectKey{}).(string)
+			return s
+		})
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		Expect(key(req)).To(Equal("ip:10.0.0.1"))
+		Expect(key(req.WithContext(context.WithValue(req.Context(), subjectKey{}, "alice")))).To(Equal("sub:alice"))
+	})
+
+	It("should not limit the requests without key", func() {
+		limiter := middleware.NewTokenBucket(1, time.Hour, middleware.NewMemoryRateLimitStore(1))
+		handler := middleware.RateLimit(limiter, middleware.RateLimitKey(func(r *http.Request) string {
+			return r.Header.Get("X-Api-Key")
+		}))(ok)
+		for i := 0; i < 3; i++ {
+			recorder := serve(handler, "10.0.0.1:1234", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("RateLimit-Limit")).To(BeEmpty())
+		}
+	})
+
+	It("should serve the requests when the store fails", func() {
+		handler := middleware.RateLimit(middleware.NewSlidingWindow(1, time.Minute, failingStore{}))(ok)
+		Expect(serve(handler, "10.0.0.1:1234", "").Code).To(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+
+	glogger "github.com/athosone/golib/pkg/logger"
+	"github.com/athosone/golib/pkg/server/renderer"
+	"github.com/pkg/errors"
+)
+
+// ErrorSink receives the panics recovered by Recover, e.g. to report them to an error tracker.
+type ErrorSink interface {
+	Report(ctx context.Context, err error, stack []byte)
+}
+
+// ErrorSinkFunc is an ErrorSink function.
+type ErrorSinkFunc func(ctx context.Context, err error, stack []byte)
+
+func (f ErrorSinkFunc) Report(ctx context.Context, err error, stack []byte) {
+	f(ctx, err, stack)
+}
+
+type recoverConfig struct {
+	sinks []ErrorSink
+}
+
+// RecoverOption configures Recover.
+type RecoverOption func(*recoverConfig)
+
+// RecoverErrorSink adds a sink receiving the recovered panics.
+func RecoverErrorSink(sink ErrorSink) RecoverOption {
+	return func(c *recoverConfig) {
+		c.sinks = append(c.sinks, sink)
+	}
+}
+
+// Recover recovers the panics of the next handlers, logs them with their stack using the logger of the context
+// and answers with a 500 problem rendered as json or xml depending on the Accept header.
+// The details of the panic are not sent to the client.
+//
+// When the response was already started, the connection is aborted as the status cannot be changed anymore.
+// http.ErrAbortHandler is propagated to net/http without logging to abort the response silently.
+//
+// Use it after RequestLogger so that the 500 responses are logged.
+func Recover(opts ...RecoverOption) func(next http.Handler) http.Handler {
+	cfg := &recoverConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &responseWrapper{ResponseWriter: w}
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				err, ok := rec.(error)
+				if ok {
+					err = errors.Wrap(err, "panic")
+				} else {
+					err = errors.Errorf("panic: %v", rec)
+				}
+				stack := debug.Stack()
+				glogger.LoggerFromContextOrDefault(r.Context()).Errorw("panic recovered",
+					"error", err.Error(),
+					"stack", string(stack),
+					"method", r.Method,
+					"url", r.URL.RequestURI(),
+				)
+				for _, sink := range cfg.sinks {
+					sink.Report(r.Context(), err, stack)
+				}
+				if rw.status != 0 || rw.written > 0 {
+					panic(http.ErrAbortHandler)
+				}
+				_ = renderer.Problem(w, r, renderer.NewProblem(http.StatusInternalServerError, ""))
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/athosone/golib/pkg/server/middleware"
+)
+
+var _ = Describe("Recover", func() {
+	var (
+		core     zapcore.Core
+		logs     *observer.ObservedLogs
+		reported []error
+	)
+
+	BeforeEach(func() {
+		core, logs = observer.New(zapcore.DebugLevel)
+		reported = nil
+	})
+
+	handlerOf := func(next http.HandlerFunc) http.Handler {
+		logger := zap.New(core).Sugar()
+		sink := middleware.ErrorSinkFunc(func(_ context.Context, err error, stack []byte) {
+			Expect(stack).ToNot(BeEmpty())
+			reported = append(reported, err)
+		})
+		return middleware.InjectLoggerInRequest(func(*http.Request) *zap.SugaredLogger { return logger })(
+			middleware.RequestLogger(nil)(middleware.Recover(middleware.RecoverErrorSink(sink))(next)))
+	}
+
+	It("should render a problem and log the panic", func() {
+		handler := handlerOf(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/books", nil)
+		request.Header.Set("Accept", "application/xml")
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+xml"))
+		Expect(recorder.Body.String()).ToNot(ContainSubstring("boom"))
+
+		Expect(logs.FilterMessage("panic recovered").Len()).To(Equal(1))
+		fields := logs.FilterMessage("panic recovered").All()[0].ContextMap()
+		Expect(fields["error"]).To(Equal("panic: boom"))
+		Expect(fields["stack"]).To(ContainSubstring("recover_test.go"))
+		Expect(logs.FilterMessage("GET /books 500").Len()).To(Equal(1))
+
+		Expect(reported).To(HaveLen(1))
+		Expect(reported[0]).To(MatchError("panic: boom"))
+	})
+
+	It("should keep the panicking errors", func() {
+		errBoom := errors.New("boom")
+		handler := handlerOf(func(w http.ResponseWriter, r *http.Request) {
+			panic(errBoom)
+		})
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/books", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+		Expect(reported).To(HaveLen(1))
+		Expect(errors.Is(reported[0], errBoom)).To(BeTrue())
+	})
+
+	It("should abort the response already started", func() {
+		handler := handlerOf(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("partial"))
+			panic("boom")
+		})
+		Expect(func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books", nil))
+		}).To(PanicWith(http.ErrAbortHandler))
+		Expect(logs.FilterMessage("panic recovered").Len()).To(Equal(1))
+		Expect(reported).To(HaveLen(1))
+	})
+
+	It("should propagate http.ErrAbortHandler silently", func() {
+		handler := handlerOf(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})
+		Expect(func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books", nil))
+		}).To(PanicWith(http.ErrAbortHandler))
+		Expect(logs.FilterMessage("panic recovered").Len()).To(Equal(0))
+		Expect(reported).To(BeEmpty())
+	})
+})
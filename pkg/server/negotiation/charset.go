@@ -0,0 +1,36 @@
+package negotiation
+
+import "strings"
+
+// Charset returns the available charset preferred by the Accept-Charset header (RFC 9110 section 12.5.2),
+// "*" matches the charsets not listed. The first available charset is returned without header.
+// It returns false when none of the available charsets is acceptable.
+func Charset(header string, available ...string) (string, bool) {
+	if len(available) == 0 {
+		return "", false
+	}
+	ranges := ParseWeighted(header)
+	if len(ranges) == 0 {
+		return available[0], true
+	}
+	selected, bestQuality := "", 0.0
+	for _, charset := range available {
+		if q := charsetQuality(ranges, charset); q > bestQuality {
+			selected, bestQuality = charset, q
+		}
+	}
+	return selected, bestQuality > 0
+}
+
+func charsetQuality(ranges []Weighted, charset string) float64 {
+	wildcard, hasWildcard := 0.0, false
+	for _, r := range ranges {
+		if strings.EqualFold(r.Value, charset) {
+			return r.Quality
+		}
+		if r.Value == "*" && !hasWildcard {
+			wildcard, hasWildcard = r.Quality, true
+		}
+	}
+	return wildcard
+}
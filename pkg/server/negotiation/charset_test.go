@@ -0,0 +1,30 @@
+package negotiation_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server/negotiation"
+)
+
+var _ = Describe("Charset", func() {
+	It("should return the preferred available charset", func() {
+		charset, ok := negotiation.Charset("iso-8859-1;q=0.8, UTF-8", "iso-8859-1", "utf-8")
+		Expect(ok).To(BeTrue())
+		Expect(charset).To(Equal("utf-8"))
+	})
+	It("should match the charsets not listed with a wildcard", func() {
+		charset, ok := negotiation.Charset("utf-8;q=0, *;q=0.5", "utf-8", "windows-1252")
+		Expect(ok).To(BeTrue())
+		Expect(charset).To(Equal("windows-1252"))
+	})
+	It("should return the first charset without header", func() {
+		charset, ok := negotiation.Charset("", "utf-8", "iso-8859-1")
+		Expect(ok).To(BeTrue())
+		Expect(charset).To(Equal("utf-8"))
+	})
+	It("should fail when no charset is acceptable", func() {
+		_, ok := negotiation.Charset("shift_jis", "utf-8")
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,120 @@
+package negotiation
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type contextLanguageKey struct{}
+
+// Lookup returns the available language tag best matching the Accept-Language header
+// with the lookup scheme of RFC 4647 section 3.4: https://www.rfc-editor.org/rfc/rfc4647#section-3.4
+// Each range is progressively truncated (de-CH-1996 -> de-CH -> de) until an available tag matches it.
+// Tags excluded with q=0 are never returned, fallback is returned when no tag matches.
+func Lookup(header string, available []string, fallback string) string {
+	ranges := ParseWeighted(header)
+	for _, r := range ranges {
+		if r.Quality == 0 {
+			break
+		}
+		if r.Value == "*" {
+			continue
+		}
+		for prefix := r.Value; prefix != ""; prefix = truncate(prefix) {
+			for _, tag := range available {
+				if strings.EqualFold(tag, prefix) && !excluded(ranges, tag) {
+					return tag
+				}
+			}
+		}
+	}
+	return fallback
+}
+
+// truncate removes the last subtag of the range, and the single letter subtag preceding it (e.g.: the "x" of private uses).
+func truncate(r string) string {
+	i := strings.LastIndexByte(r, '-')
+	if i < 0 {
+		return ""
+	}
+	r = r[:i]
+	if i = strings.LastIndexByte(r, '-'); i >= 0 && len(r)-i == 2 {
+		r = r[:i]
+	}
+	return r
+}
+
+// Filter returns the available language tags matched by the Accept-Language header, ordered by preference,
+// with the basic filtering scheme of RFC 4647 section 3.3.1: https://www.rfc-editor.org/rfc/rfc4647#section-3.3.1
+// A range matches the tags it equals or prefixes followed by "-" (de matches de-CH), "*" matches every tag.
+func Filter(header string, available []string) []string {
+	ranges := ParseWeighted(header)
+	var (
+		tags []string
+		seen = map[string]bool{}
+	)
+	for _, r := range ranges {
+		if r.Quality == 0 {
+			break
+		}
+		for _, tag := range available {
+			if !seen[tag] && matchLanguage(r.Value, tag) && !excluded(ranges, tag) {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// excluded is true when the longest range matching the tag has q=0.
+func excluded(ranges []Weighted, tag string) bool {
+	best, quality := -1, 1.0
+	for _, r := range ranges {
+		length := len(r.Value)
+		if r.Value == "*" {
+			length = 0
+		}
+		if length > best && matchLanguage(r.Value, tag) {
+			best, quality = length, r.Quality
+		}
+	}
+	return quality == 0
+}
+
+func matchLanguage(r, tag string) bool {
+	if r == "*" {
+		return true
+	}
+	if len(tag) < len(r) || !strings.EqualFold(tag[:len(r)], r) {
+		return false
+	}
+	return len(tag) == len(r) || tag[len(r)] == '-'
+}
+
+// Language negotiates the language of the response among the available ones, see Lookup.
+// The language is stored in the request context (see LanguageFromContext) and sent in the Content-Language header.
+func Language(available []string, fallback string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lang := Lookup(r.Header.Get(HeaderAcceptLanguage), available, fallback)
+			AddVary(w.Header(), HeaderAcceptLanguage)
+			if lang != "" {
+				w.Header().Set(HeaderContentLanguage, lang)
+			}
+			next.ServeHTTP(w, r.WithContext(NewLanguageContext(r.Context(), lang)))
+		})
+	}
+}
+
+// NewLanguageContext returns a context carrying the language of the response.
+func NewLanguageContext(parent context.Context, lang string) context.Context {
+	return context.WithValue(parent, contextLanguageKey{}, lang)
+}
+
+// LanguageFromContext returns the language negotiated by the Language middleware, empty when none.
+func LanguageFromContext(ctx context.Context) string {
+	lang, _ := ctx.Value(contextLanguageKey{}).(string)
+	return lang
+}
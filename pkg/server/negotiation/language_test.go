@@ -0,0 +1,75 @@
+package negotiation_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server/negotiation"
+)
+
+var _ = Describe("Language", func() {
+	available := []string{"en", "fr-CH", "fr", "de-DE"}
+
+	Describe("Lookup", func() {
+		It("should return the exact tag", func() {
+			Expect(negotiation.Lookup("fr-CH, fr;q=0.9", available, "en")).To(Equal("fr-CH"))
+		})
+		It("should truncate the range until a tag matches", func() {
+			Expect(negotiation.Lookup("de-DE-1996", available, "en")).To(Equal("de-DE"))
+			Expect(negotiation.Lookup("fr-BE", available, "en")).To(Equal("fr"))
+		})
+		It("should follow the quality order", func() {
+			Expect(negotiation.Lookup("fr;q=0.5, de-DE;q=0.8", available, "en")).To(Equal("de-DE"))
+		})
+		It("should remove the single letter subtags", func() {
+			Expect(negotiation.Lookup("fr-CH-x-private", available, "en")).To(Equal("fr-CH"))
+		})
+		It("should be case insensitive", func() {
+			Expect(negotiation.Lookup("FR-ch", available, "en")).To(Equal("fr-CH"))
+		})
+		It("should not return excluded tags", func() {
+			Expect(negotiation.Lookup("fr-CH-1996, fr-CH;q=0", available, "en")).To(Equal("fr"))
+			Expect(negotiation.Lookup("fr-CH, *;q=0", available, "en")).To(Equal("fr-CH"))
+		})
+		It("should return the fallback", func() {
+			Expect(negotiation.Lookup("", available, "en")).To(Equal("en"))
+			Expect(negotiation.Lookup("*", available, "en")).To(Equal("en"))
+			Expect(negotiation.Lookup("ja", available, "en")).To(Equal("en"))
+		})
+	})
+
+	Describe("Filter", func() {
+		It("should return the tags prefixed by the ranges", func() {
+			Expect(negotiation.Filter("fr", available)).To(Equal([]string{"fr-CH", "fr"}))
+		})
+		It("should order the tags by preference", func() {
+			Expect(negotiation.Filter("de;q=0.5, en", available)).To(Equal([]string{"en", "de-DE"}))
+		})
+		It("should exclude the tags of more specific ranges with q=0", func() {
+			Expect(negotiation.Filter("*, fr-CH;q=0", available)).To(Equal([]string{"en", "fr", "de-DE"}))
+		})
+		It("should not match partial subtags", func() {
+			Expect(negotiation.Filter("d", available)).To(BeEmpty())
+		})
+	})
+
+	Describe("middleware", func() {
+		It("should store the language and set the headers", func() {
+			var lang string
+			handler := negotiation.Language(available, "en")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lang = negotiation.LanguageFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Language", "fr-FR, fr;q=0.9")
+			recorder := httptest.NewRecorder()
+			recorder.Header().Add("Vary", "Accept-Encoding")
+			handler.ServeHTTP(recorder, req)
+			Expect(lang).To(Equal("fr"))
+			Expect(recorder.Header().Get("Content-Language")).To(Equal("fr"))
+			Expect(recorder.Header().Values("Vary")).To(Equal([]string{"Accept-Encoding", "Accept-Language"}))
+		})
+	})
+})
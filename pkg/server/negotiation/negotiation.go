@@ -0,0 +1,71 @@
+// Package negotiation implements the proactive negotiation of the language and the charset of responses,
+// media types are negotiated by server.ParseAccept.
+package negotiation
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	HeaderAcceptLanguage  = "Accept-Language"
+	HeaderAcceptCharset   = "Accept-Charset"
+	HeaderAcceptEncoding  = "Accept-Encoding"
+	HeaderContentLanguage = "Content-Language"
+	HeaderVary            = "Vary"
+)
+
+// Weighted is a value of a header holding a list of values with qualities, e.g.: Accept-Language: fr-CH, fr;q=0.9, *;q=0.5
+type Weighted struct {
+	Value   string
+	Quality float64
+}
+
+// ParseWeighted returns the values of the header ordered by quality, the header order is kept on ties.
+// Values with an invalid quality are skipped, values with q=0 are kept since they exclude the matching values.
+func ParseWeighted(header string) []Weighted {
+	var values []Weighted
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		w := Weighted{Value: value, Quality: 1}
+		if key, q, ok := strings.Cut(params, "="); ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+			quality, err := strconv.ParseFloat(strings.TrimSpace(q), 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+			w.Quality = quality
+		}
+		values = append(values, w)
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Quality > values[j].Quality
+	})
+	return values
+}
+
+// AddVary adds the header fields to the Vary header unless already listed.
+func AddVary(h http.Header, fields ...string) {
+	for _, field := range fields {
+		if !hasVary(h, field) {
+			h.Add(HeaderVary, field)
+		}
+	}
+}
+
+func hasVary(h http.Header, field string) bool {
+	for _, value := range h.Values(HeaderVary) {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if v == "*" || strings.EqualFold(v, field) {
+				return true
+			}
+		}
+	}
+	return false
+}
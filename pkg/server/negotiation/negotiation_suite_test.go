@@ -0,0 +1,13 @@
+package negotiation_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestNegotiation(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Negotiation Suite")
+}
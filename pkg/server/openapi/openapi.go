@@ -0,0 +1,228 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/athosone/golib/pkg/server/renderer"
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+const Version = "3.1.0"
+
+// Document is an OpenAPI 3.1 document: https://spec.openapis.org/oas/v3.1.0
+type Document struct {
+	OpenAPI    string               `json:"openapi" yaml:"openapi"`
+	Info       Info                 `json:"info" yaml:"info"`
+	Paths      map[string]*PathItem `json:"paths" yaml:"paths"`
+	Components *Components          `json:"components,omitempty" yaml:"components,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title" yaml:"title"`
+	Version     string `json:"version" yaml:"version"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+}
+
+// PathItem holds the operations of a path by lower case method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string               `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses" yaml:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name" yaml:"name"`
+	In       string  `json:"in" yaml:"in"`
+	Required bool    `json:"required" yaml:"required"`
+	Schema   *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content" yaml:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description" yaml:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+// Generator turns a tree of GRouters into an OpenAPI document.
+type Generator struct {
+	Info Info
+	// Formats replace the "+*" wildcard suffix of the media types, defaults to json, yaml and xml.
+	Formats []string
+}
+
+// Generate creates the document of the routes of root and its sub-routers.
+func Generate(info Info, root *routing.GRouter) *Document {
+	return Generator{Info: info}.Generate(root)
+}
+
+func (g Generator) Generate(root *routing.GRouter) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    g.Info,
+		Paths:   map[string]*PathItem{},
+	}
+	schemas := newSchemaRegistry()
+	for _, ri := range root.Routes() {
+		path, params := openAPIPath(ri.Pattern)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		method := strings.ToLower(ri.Method)
+		op, ok := (*item)[method]
+		if !ok {
+			op = &Operation{Summary: ri.Summary, Deprecated: ri.Deprecated, Parameters: params, Responses: map[string]*Response{}}
+			(*item)[method] = op
+		}
+		if op.Summary == "" {
+			op.Summary = ri.Summary
+		}
+		g.addRequest(op, ri, schemas)
+		g.addResponses(op, ri, schemas)
+	}
+	if len(schemas.components) > 0 {
+		doc.Components = &Components{Schemas: schemas.components}
+	}
+	return doc
+}
+
+// Handler serves the document encoded with the format negotiated by the renderer.
+func Handler(doc *Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = renderer.OK(w, r, doc)
+	}
+}
+
+func (g Generator) addRequest(op *Operation, ri routing.RouteInfo, schemas *schemaRegistry) {
+	if len(ri.Consumes) == 0 {
+		return
+	}
+	if op.RequestBody == nil {
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{}}
+	}
+	for _, mt := range g.expand(ri.Consumes) {
+		var schema *Schema
+		if ri.Request != nil {
+			schema = schemas.schemaOf(versionType(ri.Request, mt, ri.Versions))
+		}
+		op.RequestBody.Content[mt] = &MediaType{Schema: schema}
+	}
+}
+
+func (g Generator) addResponses(op *Operation, ri routing.RouteInfo, schemas *schemaRegistry) {
+	statuses := make([]int, 0, len(ri.Responses))
+	for status := range ri.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	if len(statuses) == 0 {
+		statuses = append(statuses, http.StatusOK)
+	}
+	for _, status := range statuses {
+		key := strconv.Itoa(status)
+		resp, ok := op.Responses[key]
+		if !ok {
+			resp = &Response{Description: http.StatusText(status)}
+			op.Responses[key] = resp
+		}
+		if len(ri.Produces) == 0 {
+			continue
+		}
+		if resp.Content == nil {
+			resp.Content = map[string]*MediaType{}
+		}
+		for _, mt := range g.expand(ri.Produces) {
+			var schema *Schema
+			if t := ri.Responses[status]; t != nil {
+				schema = schemas.schemaOf(versionType(t, mt, ri.Versions))
+			}
+			resp.Content[mt] = &MediaType{Schema: schema}
+		}
+	}
+}
+
+// versionType returns the type of a body in the version of the media type: the route registers the canonical type
+// the handler works with and the renderer converts it, or the items of a slice, to the type of the version.
+func versionType(t reflect.Type, mediaType string, vs *renderer.Versions) reflect.Type {
+	if vs == nil {
+		return t
+	}
+	canonical, ok := vs.TypeOf(vs.Canonical())
+	if !ok {
+		return t
+	}
+	version, ok := vs.TypeOf(vs.MediaTypeVersion(mediaType))
+	if !ok {
+		return t
+	}
+	switch {
+	case t == canonical:
+		return version
+	case t.Kind() == reflect.Pointer && t.Elem() == canonical:
+		return reflect.PointerTo(version)
+	case t.Kind() == reflect.Slice && t.Elem() == canonical:
+		return reflect.SliceOf(version)
+	}
+	return t
+}
+
+// expand replaces the "+*" wildcard suffix by every format.
+func (g Generator) expand(mediaTypes []string) []string {
+	formats := g.Formats
+	if len(formats) == 0 {
+		formats = []string{"json", "yaml", "xml"}
+	}
+	var expanded []string
+	for _, mt := range mediaTypes {
+		if !strings.Contains(mt, "+*") {
+			expanded = append(expanded, mt)
+			continue
+		}
+		for _, f := range formats {
+			expanded = append(expanded, strings.Replace(mt, "+*", "+"+f, 1))
+		}
+	}
+	return expanded
+}
+
+// openAPIPath converts a GRouter pattern to an OpenAPI path and its parameters,
+// the trailing wildcard becomes the path parameter named wildcard.
+func openAPIPath(pattern string) (string, []Parameter) {
+	var params []Parameter
+	segments := strings.Split(pattern, "/")
+	for i, s := range segments {
+		name := ""
+		switch {
+		case s == "*":
+			name = "wildcard"
+			segments[i] = "{wildcard}"
+		case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}"):
+			name = s[1 : len(s)-1]
+		default:
+			continue
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	return strings.Join(segments, "/"), params
+}
@@ -0,0 +1,158 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server/openapi"
+	"github.com/athosone/golib/pkg/server/renderer"
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+type rating struct {
+	Grade string `json:"grade"`
+}
+
+type ratingV1 struct {
+	Stars int `json:"stars"`
+}
+
+type book struct {
+	Name      string    `json:"fullName"`
+	Ratings   []rating  `json:"ratings,omitempty"`
+	Published time.Time `json:"published"`
+	Related   *book     `json:"related"`
+	internal  string
+}
+
+var _ = Describe("OpenAPI", func() {
+	const (
+		bookType   = "application/vnd.athosone.book+*; v=v2"
+		ratingType = "application/vnd.athosone.book.rating+json; v=v2"
+	)
+	var (
+		root *routing.GRouter
+		doc  *openapi.Document
+	)
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+
+	BeforeEach(func() {
+		root = routing.NewRouter()
+		root.Route("/books", func(r *routing.GRouter) {
+			r.Get(noop).Produce(bookType).Summary("List books").Response(http.StatusOK, []book{}).SetDefault()
+			r.Route("/{id}/ratings", func(r *routing.GRouter) {
+				r.Post(noop).Consume(ratingType).Produce(ratingType).Request(rating{}).Response(http.StatusCreated, rating{})
+			})
+		})
+	})
+	JustBeforeEach(func() {
+		doc = openapi.Generator{Info: openapi.Info{Title: "books", Version: "v2"}, Formats: []string{"json"}}.Generate(root)
+	})
+
+	It("should walk the routes", func() {
+		routes := root.Routes()
+		Expect(routes).To(HaveLen(2))
+		Expect(routes[0].Method).To(Equal(http.MethodGet))
+		Expect(routes[0].Pattern).To(Equal("/books"))
+		Expect(routes[0].Produces).To(Equal([]string{bookType}))
+		Expect(routes[0].Default).To(BeTrue())
+		Expect(routes[0].Summary).To(Equal("List books"))
+		Expect(routes[1].Method).To(Equal(http.MethodPost))
+		Expect(routes[1].Pattern).To(Equal("/books/{id}/ratings"))
+		Expect(routes[1].Consumes).To(Equal([]string{ratingType}))
+	})
+
+	It("should generate an OpenAPI 3.1 document", func() {
+		data, err := json.Marshal(doc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{
+			"openapi": "3.1.0",
+			"info": {"title": "books", "version": "v2"},
+			"paths": {
+				"/books": {
+					"get": {
+						"summary": "List books",
+						"responses": {
+							"200": {
+								"description": "OK",
+								"content": {
+									"application/vnd.athosone.book+json; v=v2": {
+										"schema": {"type": "array", "items": {"$ref": "#/components/schemas/book"}}
+									}
+								}
+							}
+						}
+					}
+				},
+				"/books/{id}/ratings": {
+					"post": {
+						"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+						"requestBody": {
+							"required": true,
+							"content": {
+								"application/vnd.athosone.book.rating+json; v=v2": {"schema": {"$ref": "#/components/schemas/rating"}}
+							}
+						},
+						"responses": {
+							"201": {
+								"description": "Created",
+								"content": {
+									"application/vnd.athosone.book.rating+json; v=v2": {"schema": {"$ref": "#/components/schemas/rating"}}
+								}
+							}
+						}
+					}
+				}
+			},
+			"components": {
+				"schemas": {
+					"book": {
+						"type": "object",
+						"properties": {
+							"fullName": {"type": "string"},
+							"ratings": {"type": "array", "items": {"$ref": "#/components/schemas/rating"}},
+							"published": {"type": "string", "format": "date-time"},
+							"related": {"$ref": "#/components/schemas/book"}
+						},
+						"required": ["fullName", "published"]
+					},
+					"rating": {
+						"type": "object",
+						"properties": {"grade": {"type": "string"}},
+						"required": ["grade"]
+					}
+				}
+			}
+		}`))
+	})
+
+	When("the route has versions", func() {
+		BeforeEach(func() {
+			vs := renderer.NewVersions("v2")
+			renderer.Convert(vs, "v2", "v1", func(r rating) (ratingV1, error) {
+				return ratingV1{Stars: len(r.Grade)}, nil
+			})
+			root = routing.NewRouter()
+			root.Get(noop).Produce(ratingType, "application/vnd.athosone.book.rating+json; v=v1").
+				Response(http.StatusOK, []rating{}).Versions(vs)
+		})
+		It("should document the type of each version", func() {
+			content := (*doc.Paths["/"])["get"].Responses["200"].Content
+			Expect(content[ratingType].Schema.Items.Ref).To(Equal("#/components/schemas/rating"))
+			Expect(content["application/vnd.athosone.book.rating+json; v=v1"].Schema.Items.Ref).To(Equal("#/components/schemas/ratingV1"))
+			Expect(doc.Components.Schemas["ratingV1"].Properties["stars"]).To(Equal(&openapi.Schema{Type: "integer", Format: "int64"}))
+		})
+	})
+
+	When("media types have a wildcard suffix", func() {
+		It("should expand them with every format", func() {
+			doc = openapi.Generate(openapi.Info{Title: "books", Version: "v2"}, root)
+			Expect((*doc.Paths["/books"])["get"].Responses["200"].Content).To(HaveLen(3))
+			Expect((*doc.Paths["/books"])["get"].Responses["200"].Content).To(HaveKey("application/vnd.athosone.book+yaml; v=v2"))
+		})
+	})
+})
@@ -1,53 +1,26 @@
 package server
 
 import (
-	"container/heap"
 	"errors"
-	"mime"
-	"strconv"
-	"strings"
 )
 
-// Based on accept header specified in: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Accept
+// ParseMediaType returns the acceptable media types of the header value as a max heap, see ParseAccept.
+// Ranges with q=0 are not returned.
 func ParseMediaType(headerValue string) (MaxContentTypeHeap, error) {
-	if headerValue == "" {
-		return MaxContentTypeHeap{}, nil
+	list, err := ParseAccept(headerValue)
+	if err != nil {
+		return MaxContentTypeHeap{}, err
 	}
-	var mh MaxContentTypeHeap
-	for _, media := range strings.Split(headerValue, ",") {
-		mediaType, params, err := mime.ParseMediaType(media)
-		if err != nil {
-			continue
+	mh := make(MaxContentTypeHeap, 0, len(list))
+	for _, mt := range list {
+		if mt.Quality > 0 {
+			mh = append(mh, mt)
 		}
-
-		_, isQualitySet := params["q"]
-		qFloat := 1.0
-		if isQualitySet {
-			qFloat, err = strconv.ParseFloat(params["q"], 64)
-			if err != nil {
-				continue
-			}
-		}
-		format := "*"
-		formats := strings.Split(mediaType, "/")
-		if len(formats) == 2 {
-			format = formats[1]
-		}
-		if strings.Contains(format, "+") {
-			splitStr := strings.Split(format, "+")
-			if len(splitStr) > 2 {
-				continue
-			}
-			format = splitStr[1]
-		}
-		isAny := mediaType == "*" || mediaType == "*/*"
-		delete(params, "q")
-		mt := ContentMediaType{mime.FormatMediaType(mediaType, params), mediaType, format, qFloat, isQualitySet, isAny}
-		heap.Push(&mh, mt)
 	}
-	if len(mh) == 0 {
-		return mh, errors.New("no supported content type found")
+	if len(mh) == 0 && len(list) > 0 {
+		return mh, errors.New("no acceptable content type found")
 	}
+	// the list is ordered by preference, which satisfies the heap invariant
 	return mh, nil
 }
 
@@ -56,6 +29,8 @@ type ContentMediaType struct {
 	Quality                          float64
 	IsQualitySet                     bool
 	IsAny                            bool
+	// Params are the parameters of the media type, q excluded.
+	Params map[string]string
 }
 
 // max heap on media type
@@ -65,10 +40,7 @@ type MaxContentTypeHeap []ContentMediaType
 func (h MaxContentTypeHeap) Len() int { return len(h) }
 
 func (h MaxContentTypeHeap) Less(i, j int) bool {
-	if h[i].Quality == h[j].Quality {
-		return h[i].IsQualitySet
-	}
-	return h[i].Quality > h[j].Quality
+	return h[i].prefer(h[j])
 }
 
 // Heap interface implementation
@@ -73,6 +73,7 @@ var _ = Describe("ParseContentType", func() {
 			Format:             "json",
 			Quality:            1.0,
 			IsQualitySet:       false,
+			Params:             map[string]string{"v": "1"},
 		}
 		actual, err := server.ParseMediaType(contentType)
 		Expect(err).To(BeNil())
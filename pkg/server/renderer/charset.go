@@ -0,0 +1,84 @@
+package renderer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/athosone/golib/pkg/server/negotiation"
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+// isText is true for the outputs that can be transcoded: text/* media types, xml and yaml.
+// json is always encoded in utf-8 (RFC 8259).
+func isText(mediaType string, format string) bool {
+	return strings.HasPrefix(mediaType, "text/") || format == "xml" || format == "yaml"
+}
+
+// transcode converts the utf-8 buffer to the charset negotiated by charsetEncoder.
+func (rd *Renderer) transcode(w http.ResponseWriter, r *http.Request, mediaType string, codec Codec, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	enc, err := rd.charsetEncoder(w, r, mediaType, codec.Format())
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return buf, nil
+	}
+	out, err := enc.Bytes(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to transcode the response")
+	}
+	return bytes.NewBuffer(out), nil
+}
+
+// transcodeWriter returns a writer converting the utf-8 output to the charset negotiated by charsetEncoder.
+// It must be closed once the output is written.
+func (rd *Renderer) transcodeWriter(w http.ResponseWriter, r *http.Request, mediaType string, format string) (io.WriteCloser, error) {
+	enc, err := rd.charsetEncoder(w, r, mediaType, format)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return nopWriteCloser{w}, nil
+	}
+	return transform.NewWriter(w, enc), nil
+}
+
+// charsetEncoder negotiates the charset of a text output with the Accept-Charset header among the Charsets
+// of the renderer and adds the charset parameter to the Content-Type header.
+// It returns nil when the output stays in utf-8.
+func (rd *Renderer) charsetEncoder(w http.ResponseWriter, r *http.Request, mediaType string, format string) (*encoding.Encoder, error) {
+	if len(rd.Charsets) == 0 || !isText(mediaType, format) {
+		return nil, nil
+	}
+	negotiation.AddVary(w.Header(), negotiation.HeaderAcceptCharset)
+	charset, ok := negotiation.Charset(r.Header.Get(negotiation.HeaderAcceptCharset), rd.Charsets...)
+	if !ok {
+		// RFC 9110 allows to disregard the header rather than answering 406
+		charset = "utf-8"
+	}
+	charset = strings.ToLower(charset)
+	if mt, params, err := mime.ParseMediaType(mediaType); err == nil {
+		params["charset"] = charset
+		w.Header().Set("Content-Type", mime.FormatMediaType(mt, params))
+	}
+	if charset == "utf-8" {
+		return nil, nil
+	}
+	enc, err := ianaindex.IANA.Encoding(charset)
+	if err != nil || enc == nil {
+		return nil, errors.Errorf("unsupported charset: %s", charset)
+	}
+	return encoding.ReplaceUnsupported(enc.NewEncoder()), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
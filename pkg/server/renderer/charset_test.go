@@ -0,0 +1,65 @@
+package renderer_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server/renderer"
+)
+
+var _ = Describe("Charset", func() {
+	var (
+		rd       *renderer.Renderer
+		request  *http.Request
+		recorder *httptest.ResponseRecorder
+	)
+	BeforeEach(func() {
+		rd = renderer.New()
+		rd.Charsets = []string{"utf-8", "iso-8859-1"}
+		request, _ = http.NewRequest("GET", "https://example.com/", nil)
+		recorder = httptest.NewRecorder()
+	})
+
+	It("should transcode text outputs to the negotiated charset", func() {
+		request.Header.Set("Accept", "application/yaml")
+		request.Header.Set("Accept-Charset", "iso-8859-1")
+		Expect(rd.OK(recorder, request, testStruct{Name: "café"})).To(Succeed())
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/yaml; charset=iso-8859-1"))
+		Expect(recorder.Header().Get("Vary")).To(Equal("Accept-Charset"))
+		Expect(recorder.Body.Bytes()).To(Equal([]byte("nameYaml: caf\xe9\n")))
+	})
+	It("should use the first charset without Accept-Charset", func() {
+		request.Header.Set("Accept", "application/yaml")
+		Expect(rd.OK(recorder, request, testStruct{Name: "café"})).To(Succeed())
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/yaml; charset=utf-8"))
+		Expect(recorder.Body.String()).To(Equal("nameYaml: café\n"))
+	})
+	It("should transcode streamed outputs", func() {
+		request.Header.Set("Accept", "application/yaml")
+		request.Header.Set("Accept-Charset", "iso-8859-1")
+		request = request.WithContext(renderer.NewContext(request.Context(), rd))
+		items := []testStruct{{Name: "café"}, {Name: "thé"}}
+		Expect(renderer.StreamIterator(recorder, request, http.StatusOK, renderer.FromSlice(items))).To(Succeed())
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/yaml; charset=iso-8859-1"))
+		Expect(recorder.Header().Get("Vary")).To(Equal("Accept-Charset"))
+		Expect(recorder.Body.Bytes()).To(Equal([]byte("nameYaml: caf\xe9\n---\nnameYaml: th\xe9\n")))
+	})
+	It("should transcode the outputs of a streaming renderer", func() {
+		rd.Streaming = true
+		request.Header.Set("Accept", "application/yaml")
+		request.Header.Set("Accept-Charset", "iso-8859-1")
+		Expect(rd.OK(recorder, request, testStruct{Name: "café"})).To(Succeed())
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/yaml; charset=iso-8859-1"))
+		Expect(recorder.Body.Bytes()).To(Equal([]byte("nameYaml: caf\xe9\n")))
+	})
+	It("should keep json in utf-8", func() {
+		request.Header.Set("Accept", "application/json")
+		request.Header.Set("Accept-Charset", "iso-8859-1")
+		Expect(rd.OK(recorder, request, testStruct{Name: "café"})).To(Succeed())
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(ContainSubstring("café"))
+	})
+})
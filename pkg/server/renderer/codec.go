@@ -0,0 +1,94 @@
+package renderer
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Codec serializes and deserializes a format.
+type Codec interface {
+	// Format is the name of the format as written in structured syntax suffixes,
+	// e.g.: json for application/vnd.athosone.book+json.
+	Format() string
+	// MediaType is the media type set in the Content-Type header when the client accepts any media type,
+	// e.g.: application/json.
+	MediaType() string
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+// registry holds codecs by format, it is safe for concurrent use.
+type registry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+}
+
+func (reg *registry) register(c Codec) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.codecs == nil {
+		reg.codecs = map[string]Codec{}
+	}
+	reg.codecs[c.Format()] = c
+}
+
+func (reg *registry) unregister(format string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.codecs, format)
+}
+
+func (reg *registry) lookup(format string) (Codec, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	c, ok := reg.codecs[format]
+	return c, ok
+}
+
+// JSONCodec encodes and decodes application/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Format() string    { return "json" }
+func (JSONCodec) MediaType() string { return "application/json" }
+
+func (JSONCodec) Encode(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(true)
+	return encoder.Encode(v)
+}
+
+func (JSONCodec) Decode(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// YAMLCodec encodes and decodes application/yaml.
+type YAMLCodec struct{}
+
+func (YAMLCodec) Format() string    { return "yaml" }
+func (YAMLCodec) MediaType() string { return "application/yaml" }
+
+func (YAMLCodec) Encode(w io.Writer, v any) error {
+	return yaml.NewEncoder(w).Encode(v)
+}
+
+func (YAMLCodec) Decode(r io.Reader, v any) error {
+	return yaml.NewDecoder(r).Decode(v)
+}
+
+// XMLCodec encodes and decodes application/xml.
+type XMLCodec struct{}
+
+func (XMLCodec) Format() string    { return "xml" }
+func (XMLCodec) MediaType() string { return "application/xml" }
+
+func (XMLCodec) Encode(w io.Writer, v any) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (XMLCodec) Decode(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
@@ -0,0 +1,95 @@
+package renderer_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/athosone/golib/pkg/server/renderer"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type plainCodec struct{}
+
+func (plainCodec) Format() string    { return "plain" }
+func (plainCodec) MediaType() string { return "text/plain" }
+func (plainCodec) Encode(w io.Writer, v any) error {
+	_, err := fmt.Fprint(w, v)
+	return err
+}
+func (plainCodec) Decode(r io.Reader, v any) error {
+	_, err := fmt.Fscan(r, v)
+	return err
+}
+
+var _ = Describe("Codec registry", func() {
+	var (
+		rd               *renderer.Renderer
+		responseRecorder *httptest.ResponseRecorder
+		request          *http.Request
+	)
+	BeforeEach(func() {
+		rd = renderer.New()
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "https://example.com/", nil)
+	})
+
+	When("Registering a codec", func() {
+		BeforeEach(func() {
+			rd.Register(plainCodec{})
+			request.Header.Set("Accept", "text/plain")
+		})
+		It("should encode with the registered codec", func() {
+			Expect(rd.OK(responseRecorder, request, "test")).To(Succeed())
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("text/plain"))
+			Expect(responseRecorder.Body.String()).To(Equal("test"))
+		})
+		It("should not change the default renderer", func() {
+			Expect(renderer.OK(responseRecorder, request, "test")).NotTo(Succeed())
+		})
+		It("should be used by the package functions when injected in the context", func() {
+			request = request.WithContext(renderer.NewContext(request.Context(), rd))
+			Expect(renderer.OK(responseRecorder, request, "test")).To(Succeed())
+			Expect(responseRecorder.Body.String()).To(Equal("test"))
+		})
+	})
+	When("Unregistering a codec", func() {
+		BeforeEach(func() {
+			rd.Unregister("json")
+			request.Header.Set("Accept", "application/json")
+		})
+		It("should not encode the format anymore", func() {
+			_, err := rd.Encode(responseRecorder, request, testStruct{Name: "test"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	When("Changing the default format", func() {
+		BeforeEach(func() {
+			rd.DefaultFormat = "yaml"
+			request.Header.Set("Accept", "*/*")
+		})
+		It("should use the media type of the codec", func() {
+			Expect(rd.OK(responseRecorder, request, testStruct{Name: "test"})).To(Succeed())
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("application/yaml"))
+			Expect(responseRecorder.Body.String()).To(Equal("nameYaml: test\n"))
+		})
+	})
+	It("should be safe to register concurrently", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				rd.Register(plainCodec{})
+			}()
+			go func() {
+				defer wg.Done()
+				_, _ = rd.Encode(httptest.NewRecorder(), request, "test")
+			}()
+		}
+		wg.Wait()
+	})
+})
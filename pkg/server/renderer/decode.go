@@ -0,0 +1,152 @@
+package renderer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/athosone/golib/pkg/server"
+	"github.com/pkg/errors"
+)
+
+// MaxBodySize is the maximum number of bytes read from a request body by Decode.
+// Set it to 0 or a negative value to disable the limit.
+var MaxBodySize int64 = 1 << 20
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrEmptyBody            = errors.New("request body is empty")
+	ErrBodyTooLarge         = errors.New("request body too large")
+)
+
+// DecodeError is returned by Decode when the request body cannot be read.
+// Status is the HTTP status code matching the failure (415, 413 or 400).
+type DecodeError struct {
+	Status int
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Decoding
+
+// Bind decodes the request body into v like Decode does.
+// On failure a problem holding the status code of the error is rendered and the error is returned,
+// the caller only has to stop handling the request.
+func Bind(w http.ResponseWriter, r *http.Request, v any) error {
+	return FromContext(r.Context()).Bind(w, r, v)
+}
+
+// Decode reads the request body into v.
+// The format is determined by the Content-Type header, structured syntax suffixes are supported
+// (e.g.: application/vnd.athosone.book+json is decoded as json).
+// A wildcard Content-Type is decoded with the default format.
+// The body is limited to MaxBodySize bytes.
+// When versions are carried by the request context the body is converted from the version of the Content-Type header,
+// see Versions.
+// Errors are always of type *DecodeError.
+func Decode(r *http.Request, v any) error {
+	return FromContext(r.Context()).Decode(r, v)
+}
+
+func (rd *Renderer) Bind(w http.ResponseWriter, r *http.Request, v any) error {
+	err := rd.Decode(r, v)
+	if err != nil {
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			de = &DecodeError{Status: http.StatusBadRequest, Err: err}
+		}
+		_ = rd.Problem(w, r, NewProblem(de.Status, de.Error()))
+	}
+	return err
+}
+
+func (rd *Renderer) Decode(r *http.Request, v any) error {
+	contentType := r.Header.Get("Content-Type")
+	codec, err := rd.searchDeserializer(contentType)
+	if err != nil {
+		return &DecodeError{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    errors.Wrap(ErrUnsupportedMediaType, fmt.Sprintf("invalid Content-Type header: %s", contentType)),
+		}
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return &DecodeError{Status: http.StatusBadRequest, Err: ErrEmptyBody}
+	}
+
+	var body io.Reader = r.Body
+	if MaxBodySize > 0 {
+		body = MaxBytesReader(r.Body, MaxBodySize)
+	}
+	converted, err := decodeVersion(r, codec, body, v)
+	if !converted {
+		err = codec.Decode(body, v)
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNoConversion):
+		return &DecodeError{Status: http.StatusUnsupportedMediaType, Err: err}
+	case errors.Is(err, ErrBodyTooLarge):
+		return &DecodeError{Status: http.StatusRequestEntityTooLarge, Err: err}
+	case errors.Is(err, io.EOF):
+		return &DecodeError{Status: http.StatusBadRequest, Err: ErrEmptyBody}
+	default:
+		return &DecodeError{Status: http.StatusBadRequest, Err: errors.Wrap(err, "failed to decode request body")}
+	}
+}
+
+func (rd *Renderer) searchDeserializer(contentType string) (Codec, error) {
+	if contentType == "" {
+		return nil, errors.New("no Content-Type header")
+	}
+	list, err := server.ParseAccept(contentType)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.New("no Content-Type header")
+	}
+	mt := list[0]
+	if mt.IsAny || mt.Format == "*" {
+		mt.Format = rd.defaultFormat()
+	}
+	codec, ok := rd.codecs.lookup(mt.Format)
+	if !ok {
+		return nil, errors.Errorf("no deserializer for format: %s", mt.Format)
+	}
+	return codec, nil
+}
+
+// MaxBytesReader returns a reader failing with ErrBodyTooLarge once more than n bytes are read from r.
+func MaxBytesReader(r io.Reader, n int64) io.Reader {
+	return &maxBytesReader{r: r, n: n}
+}
+
+type maxBytesReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.n <= 0 {
+		var probe [1]byte
+		n, err := m.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > m.n {
+		p = p[:m.n]
+	}
+	n, err := m.r.Read(p)
+	m.n -= int64(n)
+	return n, err
+}
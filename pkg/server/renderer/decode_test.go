@@ -0,0 +1,141 @@
+package renderer_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/athosone/golib/pkg/server/renderer"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decode", func() {
+	var (
+		request *http.Request
+		body    string
+		decoded testStruct
+		err     error
+	)
+	BeforeEach(func() {
+		decoded = testStruct{}
+	})
+	JustBeforeEach(func() {
+		request, _ = http.NewRequest("POST", "https://example.com/", strings.NewReader(body))
+	})
+	decode := func(contentType string) {
+		request.Header.Set("Content-Type", contentType)
+		err = renderer.Decode(request, &decoded)
+	}
+	statusOf := func(err error) int {
+		var de *renderer.DecodeError
+		Expect(errors.As(err, &de)).To(BeTrue())
+		return de.Status
+	}
+
+	When("Request has json content type", func() {
+		BeforeEach(func() {
+			body = `{"nameJson":"test"}`
+		})
+		It("should decode json", func() {
+			decode("application/json")
+			Expect(err).To(BeNil())
+			Expect(decoded.Name).To(Equal("test"))
+		})
+		It("should decode structured suffix types", func() {
+			decode("application/vnd.athosone.book.rating.add+json; v=v1")
+			Expect(err).To(BeNil())
+			Expect(decoded.Name).To(Equal("test"))
+		})
+		It("should decode wildcard with the default serializer", func() {
+			decode("*/*")
+			Expect(err).To(BeNil())
+			Expect(decoded.Name).To(Equal("test"))
+		})
+	})
+	When("Request has yaml content type", func() {
+		BeforeEach(func() {
+			body = "nameYaml: test\n"
+		})
+		It("should decode yaml", func() {
+			decode("application/vnd.athosone.test+yaml; v=v1beta1")
+			Expect(err).To(BeNil())
+			Expect(decoded.Name).To(Equal("test"))
+		})
+	})
+	When("Request has xml content type", func() {
+		BeforeEach(func() {
+			body = "<testStruct><NameXml>test</NameXml></testStruct>"
+		})
+		It("should decode xml", func() {
+			decode("application/xml")
+			Expect(err).To(BeNil())
+			Expect(decoded.Name).To(Equal("test"))
+		})
+	})
+	When("Request has an unsupported content type", func() {
+		BeforeEach(func() {
+			body = `{"nameJson":"test"}`
+		})
+		It("should return 415", func() {
+			decode("application/msgpack")
+			Expect(errors.Is(err, renderer.ErrUnsupportedMediaType)).To(BeTrue())
+			Expect(statusOf(err)).To(Equal(http.StatusUnsupportedMediaType))
+		})
+		It("should return 415 when content type is missing", func() {
+			decode("")
+			Expect(statusOf(err)).To(Equal(http.StatusUnsupportedMediaType))
+		})
+		It("should return 415 when content type is blank", func() {
+			decode("  ")
+			Expect(statusOf(err)).To(Equal(http.StatusUnsupportedMediaType))
+		})
+	})
+	When("Request body is invalid", func() {
+		BeforeEach(func() {
+			body = `{"nameJson":`
+		})
+		It("should return 400", func() {
+			decode("application/json")
+			Expect(statusOf(err)).To(Equal(http.StatusBadRequest))
+		})
+	})
+	When("Request body is empty", func() {
+		BeforeEach(func() {
+			body = ""
+		})
+		It("should return 400", func() {
+			decode("application/json")
+			Expect(errors.Is(err, renderer.ErrEmptyBody)).To(BeTrue())
+			Expect(statusOf(err)).To(Equal(http.StatusBadRequest))
+		})
+	})
+	When("Request body exceeds the max body size", func() {
+		var previous int64
+		BeforeEach(func() {
+			previous = renderer.MaxBodySize
+			renderer.MaxBodySize = 8
+			body = `{"nameJson":"test"}`
+		})
+		AfterEach(func() {
+			renderer.MaxBodySize = previous
+		})
+		It("should return 413", func() {
+			decode("application/json")
+			Expect(errors.Is(err, renderer.ErrBodyTooLarge)).To(BeTrue())
+			Expect(statusOf(err)).To(Equal(http.StatusRequestEntityTooLarge))
+		})
+	})
+	Context("Binding", func() {
+		BeforeEach(func() {
+			body = `{"nameJson":`
+		})
+		It("should write the error status", func() {
+			responseRecorder := httptest.NewRecorder()
+			request.Header.Set("Content-Type", "application/json")
+			Expect(renderer.Bind(responseRecorder, request, &decoded)).NotTo(Succeed())
+			Expect(responseRecorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,194 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/athosone/golib/pkg/server"
+)
+
+const (
+	ProblemJSONMediaType = "application/problem+json"
+	ProblemXMLMediaType  = "application/problem+xml"
+	problemXMLNamespace  = "urn:ietf:rfc:7807"
+)
+
+// ProblemDetails is an error response as defined by RFC 7807: https://www.rfc-editor.org/rfc/rfc7807
+// Extensions are serialized as top level members of the problem document.
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+// NewProblem creates a problem of type about:blank titled with the status text.
+func NewProblem(status int, detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// With adds an extension member to the problem.
+func (p *ProblemDetails) With(key string, value any) *ProblemDetails {
+	if p.Extensions == nil {
+		p.Extensions = map[string]any{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *ProblemDetails) Error() string {
+	if p.Detail != "" {
+		return fmt.Sprintf("%s: %s", p.Title, p.Detail)
+	}
+	return p.Title
+}
+
+func (p *ProblemDetails) members() map[string]any {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return m
+}
+
+func (p *ProblemDetails) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.members())
+}
+
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*p = ProblemDetails{}
+	for k, v := range m {
+		switch k {
+		case "type":
+			p.Type, _ = v.(string)
+		case "title":
+			p.Title, _ = v.(string)
+		case "status":
+			if f, ok := v.(float64); ok {
+				p.Status = int(f)
+			}
+		case "detail":
+			p.Detail, _ = v.(string)
+		case "instance":
+			p.Instance, _ = v.(string)
+		default:
+			p.With(k, v)
+		}
+	}
+	return nil
+}
+
+// MarshalXML follows the format of the appendix A of the RFC, arrays are written as i elements.
+func (p *ProblemDetails) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{
+		Name: xml.Name{Local: "problem"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: problemXMLNamespace}},
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	members := p.members()
+	keys := make([]string, 0, len(members))
+	for k := range members {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := encodeXMLMember(e, k, members[k]); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func encodeXMLMember(e *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return e.EncodeElement(v, start)
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.EncodeElement(rv.Index(i).Interface(), xml.StartElement{Name: xml.Name{Local: "i"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Problem renders the problem as application/problem+xml when xml is preferred by the Accept header,
+// otherwise as application/problem+json.
+// A problem without status is rendered as an internal server error.
+func Problem(w http.ResponseWriter, r *http.Request, p *ProblemDetails) error {
+	return FromContext(r.Context()).Problem(w, r, p)
+}
+
+func (rd *Renderer) Problem(w http.ResponseWriter, r *http.Request, p *ProblemDetails) error {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	var codec Codec = JSONCodec{}
+	mediaType := ProblemJSONMediaType
+	if problemFormat(r.Header.Get("Accept")) == "xml" {
+		codec, mediaType = XMLCodec{}, ProblemXMLMediaType
+	}
+	if c, ok := rd.codecs.lookup(codec.Format()); ok {
+		codec = c
+	}
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, p); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(p.Status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// problemFormat returns the first json or xml format found in the Accept header.
+func problemFormat(accept string) string {
+	list, err := server.ParseAccept(accept)
+	if err != nil {
+		return "json"
+	}
+	for _, mt := range list {
+		if mt.Quality > 0 && (mt.Format == "json" || mt.Format == "xml") {
+			return mt.Format
+		}
+	}
+	return "json"
+}
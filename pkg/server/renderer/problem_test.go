@@ -0,0 +1,77 @@
+package renderer_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/athosone/golib/pkg/server/renderer"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Problem", func() {
+	var (
+		responseRecorder *httptest.ResponseRecorder
+		request          *http.Request
+		problem          *renderer.ProblemDetails
+	)
+	BeforeEach(func() {
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "https://example.com/books/1", nil)
+		problem = renderer.NewProblem(http.StatusNotFound, "book 1 does not exist").With("book_id", "1")
+		problem.Instance = "/books/1"
+	})
+	JustBeforeEach(func() {
+		Expect(renderer.Problem(responseRecorder, request, problem)).To(Succeed())
+	})
+
+	When("Request accepts json", func() {
+		BeforeEach(func() {
+			request.Header.Set("Accept", "application/vnd.athosone.book+json; v=v2")
+		})
+		It("should render problem+json", func() {
+			Expect(responseRecorder.Code).To(Equal(http.StatusNotFound))
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal(renderer.ProblemJSONMediaType))
+			Expect(responseRecorder.Body.String()).To(MatchJSON(`{
+				"type": "about:blank",
+				"title": "Not Found",
+				"status": 404,
+				"detail": "book 1 does not exist",
+				"instance": "/books/1",
+				"book_id": "1"
+			}`))
+		})
+		It("should decode back into a problem", func() {
+			var decoded renderer.ProblemDetails
+			Expect(json.Unmarshal(responseRecorder.Body.Bytes(), &decoded)).To(Succeed())
+			Expect(&decoded).To(Equal(problem))
+		})
+	})
+	When("Request prefers xml", func() {
+		BeforeEach(func() {
+			request.Header.Set("Accept", "application/json;q=0.5, application/xml")
+			problem.With("acceptable", []string{"application/json"})
+		})
+		It("should render problem+xml", func() {
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal(renderer.ProblemXMLMediaType))
+			Expect(responseRecorder.Body.String()).To(Equal(`<problem xmlns="urn:ietf:rfc:7807">` +
+				`<acceptable><i>application/json</i></acceptable>` +
+				`<book_id>1</book_id>` +
+				`<detail>book 1 does not exist</detail>` +
+				`<instance>/books/1</instance>` +
+				`<status>404</status>` +
+				`<title>Not Found</title>` +
+				`<type>about:blank</type>` +
+				`</problem>`))
+		})
+	})
+	When("Request does not accept json nor xml", func() {
+		BeforeEach(func() {
+			request.Header.Set("Accept", "text/html")
+		})
+		It("should fallback to problem+json", func() {
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal(renderer.ProblemJSONMediaType))
+		})
+	})
+})
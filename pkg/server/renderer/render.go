@@ -2,30 +2,93 @@ package renderer
 
 import (
 	"bytes"
-	"container/heap"
-	"encoding/json"
-	"encoding/xml"
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/athosone/golib/pkg/server"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
+// DefaultSerializer is the format used by renderers without DefaultFormat
+// when the client accepts any media type.
 var DefaultSerializer = "json"
 
-var (
-	supportedSerializer = map[string]serializer{
-		"*":    renderJSON,
-		"json": renderJSON,
-		"yaml": renderYAML,
-		"xml":  renderXML,
+// Default is the renderer used by the package level functions
+// when no renderer has been injected in the request context.
+var Default = New()
+
+type contextRendererKey struct{}
+
+// Renderer encodes responses and decodes requests using its own registry of codecs.
+// Different routers can carry different renderers, see NewContext.
+type Renderer struct {
+	// DefaultFormat is the format used when the client accepts any media type.
+	// When empty the DefaultSerializer is used.
+	DefaultFormat string
+	// Streaming makes RenderResponse encode directly to the response instead of buffering the body,
+	// as a consequence encoding errors can no longer change the status code.
+	Streaming bool
+	// Charsets are the charsets text outputs (text/*, xml and yaml) can be transcoded to, negotiated with the
+	// Accept-Charset header, the first one is used without header. Outputs are utf-8 without charset parameter when empty.
+	// Names are IANA charset names, e.g.: utf-8, iso-8859-1, windows-1252, shift_jis.
+	Charsets []string
+	codecs   registry
+}
+
+// New creates a renderer supporting json, yaml and xml.
+func New() *Renderer {
+	rd := &Renderer{}
+	rd.Register(JSONCodec{})
+	rd.Register(YAMLCodec{})
+	rd.Register(XMLCodec{})
+	return rd
+}
+
+// Register adds a codec to the renderer, replacing any codec registered for the same format.
+// It is safe to call concurrently, e.g. from init functions.
+func (rd *Renderer) Register(c Codec) {
+	rd.codecs.register(c)
+}
+
+// Unregister removes the codec registered for the format.
+func (rd *Renderer) Unregister(format string) {
+	rd.codecs.unregister(format)
+}
+
+// Register adds a codec to the Default renderer.
+func Register(c Codec) {
+	Default.Register(c)
+}
+
+// Unregister removes a codec from the Default renderer.
+func Unregister(format string) {
+	Default.Unregister(format)
+}
+
+// NewContext returns a context carrying the renderer,
+// the package level functions will use it instead of the Default one.
+func NewContext(parent context.Context, rd *Renderer) context.Context {
+	return context.WithValue(parent, contextRendererKey{}, rd)
+}
+
+// FromContext returns the renderer carried by the context or the Default one.
+func FromContext(ctx context.Context) *Renderer {
+	if rd, ok := ctx.Value(contextRendererKey{}).(*Renderer); ok && rd != nil {
+		return rd
 	}
-)
+	return Default
+}
 
-type serializer func(http.ResponseWriter, any) (*bytes.Buffer, error)
+// InjectRendererInRequest injects the renderer in the request context.
+func InjectRendererInRequest(rd *Renderer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), rd)))
+		})
+	}
+}
 
 // Helper function to render response
 
@@ -57,7 +120,40 @@ func BadRequest(w http.ResponseWriter, r *http.Request, v any) error {
 // The DefaultContentType is used as default format, if you want to change it you can as its a var.
 // If the Accept header is not supported, an error is returned.
 func RenderResponse(w http.ResponseWriter, r *http.Request, status int, v any) error {
-	buf, err := Encode(w, r, v)
+	return FromContext(r.Context()).RenderResponse(w, r, status, v)
+}
+
+func Encode(w http.ResponseWriter, r *http.Request, v any) (*bytes.Buffer, error) {
+	return FromContext(r.Context()).Encode(w, r, v)
+}
+
+func (rd *Renderer) OK(w http.ResponseWriter, r *http.Request, v any) error {
+	return rd.RenderResponse(w, r, http.StatusOK, v)
+}
+
+func (rd *Renderer) Created(w http.ResponseWriter, r *http.Request, v any) error {
+	return rd.RenderResponse(w, r, http.StatusCreated, v)
+}
+
+func (rd *Renderer) Accepted(w http.ResponseWriter, r *http.Request, v any) error {
+	return rd.RenderResponse(w, r, http.StatusAccepted, v)
+}
+
+func (rd *Renderer) NotFound(w http.ResponseWriter, r *http.Request, v any) error {
+	return rd.RenderResponse(w, r, http.StatusNotFound, v)
+}
+
+func (rd *Renderer) BadRequest(w http.ResponseWriter, r *http.Request, v any) error {
+	return rd.RenderResponse(w, r, http.StatusBadRequest, v)
+}
+
+// RenderResponse renders data passed to it encoded with the codec matching the Accept header.
+// When the renderer is Streaming the data is encoded directly to the response.
+func (rd *Renderer) RenderResponse(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	if rd.Streaming {
+		return rd.Stream(w, r, status, v)
+	}
+	buf, err := rd.Encode(w, r, v)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return err
@@ -67,65 +163,79 @@ func RenderResponse(w http.ResponseWriter, r *http.Request, status int, v any) e
 	return err
 }
 
-func Encode(w http.ResponseWriter, r *http.Request, v any) (*bytes.Buffer, error) {
-	accept := r.Header.Get("Accept")
-	mediaType, err := searchContentType(accept)
+// Encode serializes v with the codec matching the Accept header and sets the Content-Type header.
+func (rd *Renderer) Encode(w http.ResponseWriter, r *http.Request, v any) (*bytes.Buffer, error) {
+	codec, mediaType, err := rd.negotiate(w, r)
 	if err != nil {
-		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse Accept header, invalid value: %s", accept))
+		return nil, err
 	}
-	if mediaType.IsAny || mediaType.Format == "*" {
-		if DefaultSerializer == "" {
-			return nil, errors.New("no Accept header and no DefaultSerializer defined")
-		}
-		mediaType.Format = DefaultSerializer
-		if mediaType.IsAny {
-			mediaType.FullyQualifiedType = "application/" + DefaultSerializer
-		}
+	if v, err = toResponseVersion(r, mediaType, v); err != nil {
+		return nil, err
 	}
-	serializer, ok := supportedSerializer[mediaType.Format]
+	var buf bytes.Buffer
+	if err = codec.Encode(&buf, v); err != nil {
+		return &buf, err
+	}
+	return rd.transcode(w, r, mediaType, codec, &buf)
+}
+
+// negotiate returns the codec matching the Accept header and sets the Content-Type header to the returned media type.
+func (rd *Renderer) negotiate(w http.ResponseWriter, r *http.Request) (Codec, string, error) {
+	accept := r.Header.Get("Accept")
+	mediaType, err := rd.searchContentType(accept)
+	if err != nil {
+		return nil, "", errors.Wrap(err, fmt.Sprintf("failed to parse Accept header, invalid value: %s", accept))
+	}
+	codec, ok := rd.codecs.lookup(mediaType.Format)
 	if !ok {
-		return nil, errors.Errorf("unsupported Accept header: %s", accept)
+		return nil, "", errors.Errorf("unsupported Accept header: %s", accept)
+	}
+	if mediaType.IsAny {
+		mediaType.FullyQualifiedType = codec.MediaType()
 	}
 
 	ft := strings.Replace(mediaType.FullyQualifiedType, "+*", "+"+mediaType.Format, 1)
 	w.Header().Set("Content-Type", ft)
-	return serializer(w, v)
+	return codec, ft, nil
 }
 
-func renderJSON(w http.ResponseWriter, data any) (*bytes.Buffer, error) {
-	var buf bytes.Buffer
-	encoder := json.NewEncoder(&buf)
-	encoder.SetEscapeHTML(true)
-
-	return &buf, encoder.Encode(data)
-}
-
-func renderYAML(w http.ResponseWriter, data any) (*bytes.Buffer, error) {
-	var buf bytes.Buffer
-	encoder := yaml.NewEncoder(&buf)
-
-	return &buf, encoder.Encode(data)
-}
-
-func renderXML(w http.ResponseWriter, data any) (*bytes.Buffer, error) {
-	var buf bytes.Buffer
-	encoder := xml.NewEncoder(&buf)
-
-	return &buf, encoder.Encode(data)
+func (rd *Renderer) defaultFormat() string {
+	if rd.DefaultFormat != "" {
+		return rd.DefaultFormat
+	}
+	return DefaultSerializer
 }
 
 // Media types
 
-func searchContentType(accept string) (*server.ContentMediaType, error) {
-	mh, err := server.ParseMediaType(accept)
+// acceptAnything replaces a missing Accept header.
+var acceptAnything = server.AcceptList{{FullyQualifiedType: "*/*", Type: "*/*", Format: "*", Quality: 1, IsAny: true}}
+
+// searchContentType walks the accepted media types by preference until one is supported by a codec.
+// Wildcard formats are resolved to the default format, unless its media type is excluded with q=0.
+func (rd *Renderer) searchContentType(accept string) (*server.ContentMediaType, error) {
+	list, err := server.ParseAccept(accept)
 	if err != nil {
 		return nil, err
 	}
-	for len(mh) > 0 {
-		mt := heap.Pop(&mh).(server.ContentMediaType)
-		if supportedSerializer[mt.Format] != nil {
-			return &mt, nil
+	if len(list) == 0 {
+		list = acceptAnything
+	}
+	for _, mt := range list {
+		if mt.Quality == 0 {
+			break
+		}
+		if mt.IsAny || mt.Format == "*" {
+			if rd.defaultFormat() == "" {
+				return nil, errors.New("no Accept header and no DefaultSerializer defined")
+			}
+			mt.Format = rd.defaultFormat()
+		}
+		codec, ok := rd.codecs.lookup(mt.Format)
+		if !ok || (mt.IsAny && list.Quality(codec.MediaType()) == 0) {
+			continue
 		}
+		return &mt, nil
 	}
 	return nil, errors.New("no supported content type found")
 }
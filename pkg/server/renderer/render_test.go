@@ -131,4 +131,20 @@ var _ = Describe("Render", func() {
 			Expect(responseRecorder.Body.String()).To(Equal("{\"nameJson\":\"test\"}\n"))
 		})
 	})
+	When("Request excludes the default format", func() {
+		BeforeEach(func() {
+			request.Header.Set("Accept", "*/*;q=0.9, application/xml;q=0.5, application/json;q=0")
+		})
+		It("should not resolve the wildcard to the default format", func() {
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("application/xml"))
+		})
+	})
+	When("Request prefers a more specific range with the same quality", func() {
+		BeforeEach(func() {
+			request.Header.Set("Accept", "*/*, application/*, application/yaml")
+		})
+		It("should use the most specific one", func() {
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("application/yaml"))
+		})
+	})
 })
@@ -0,0 +1,320 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/athosone/golib/pkg/server"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// NDJSONMediaType is the media type of newline delimited json streams.
+const NDJSONMediaType = "application/x-ndjson"
+
+var ndjsonMediaTypes = map[string]struct{}{
+	"application/x-ndjson": {},
+	"application/ndjson":   {},
+	"application/jsonl":    {},
+}
+
+// Iterator yields values until yield returns false.
+// It has the shape of the range-over-func iterators (iter.Seq).
+type Iterator[T any] func(yield func(T) bool)
+
+// FromChannel iterates over the values received on the channel until it is closed.
+func FromChannel[T any](ch <-chan T) Iterator[T] {
+	return func(yield func(T) bool) {
+		for v := range ch {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// FromChannelContext iterates over the values received on the channel until it is closed or ctx is done.
+func FromChannelContext[T any](ctx context.Context, ch <-chan T) Iterator[T] {
+	return func(yield func(T) bool) {
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok || !yield(v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// FromSlice iterates over the values of the slice.
+func FromSlice[T any](values []T) Iterator[T] {
+	return func(yield func(T) bool) {
+		for _, v := range values {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+type streamConfig struct {
+	flushEvery    int
+	flushInterval time.Duration
+}
+
+// StreamOption configures StreamIterator and StreamChannel.
+type StreamOption func(*streamConfig)
+
+// FlushEvery flushes the response every n items, 0 disables it.
+func FlushEvery(n int) StreamOption {
+	return func(c *streamConfig) {
+		c.flushEvery = n
+	}
+}
+
+// FlushInterval flushes the response when an item is written at least d after the previous flush, 0 disables it.
+// Defaults to 1s.
+func FlushInterval(d time.Duration) StreamOption {
+	return func(c *streamConfig) {
+		c.flushInterval = d
+	}
+}
+
+// Stream encodes v directly to the response with the codec matching the Accept header.
+// Headers and status are committed before encoding, encoding errors are only returned.
+func Stream(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	return FromContext(r.Context()).Stream(w, r, status, v)
+}
+
+func (rd *Renderer) Stream(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	codec, mediaType, err := rd.negotiate(w, r)
+	if err == nil {
+		v, err = toResponseVersion(r, mediaType, v)
+	}
+	var out io.WriteCloser
+	if err == nil {
+		out, err = rd.transcodeWriter(w, r, mediaType, codec.Format())
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	w.WriteHeader(status)
+	if err = codec.Encode(out, v); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// StreamChannel renders the values received on the channel until it is closed or the request context is done,
+// see StreamIterator.
+func StreamChannel[T any](w http.ResponseWriter, r *http.Request, status int, ch <-chan T, opts ...StreamOption) error {
+	return StreamIterator(w, r, status, FromChannelContext(r.Context(), ch), opts...)
+}
+
+// StreamIterator renders the values of the iterator as they are produced.
+// The Accept header selects the stream format:
+//   - application/x-ndjson (or application/ndjson, application/jsonl): one json document per line
+//   - json formats: a json array
+//   - yaml formats: a yaml multi-document stream
+//
+// Wildcards are resolved to the default format of the renderer found in the request context.
+// The response is flushed periodically, see FlushEvery and FlushInterval.
+// Iteration stops when the request context is done. Iterators blocking while waiting for their values
+// should watch the context too, as FromChannelContext does.
+func StreamIterator[T any](w http.ResponseWriter, r *http.Request, status int, seq Iterator[T], opts ...StreamOption) error {
+	cfg := streamConfig{flushInterval: time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	rd := FromContext(r.Context())
+	enc, mediaType, err := rd.negotiateStream(w, r)
+	var out io.WriteCloser
+	if err == nil {
+		out, err = rd.transcodeWriter(w, r, mediaType, enc.format())
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	w.WriteHeader(status)
+
+	sw := &streamWriter{w: w, cfg: cfg}
+	sw.flush()
+	if err = enc.begin(out); err != nil {
+		return err
+	}
+	ctx := r.Context()
+	seq(func(v T) bool {
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+		var item any
+		if item, err = toResponseVersion(r, mediaType, v); err != nil {
+			return false
+		}
+		if err = enc.item(out, item); err != nil {
+			return false
+		}
+		sw.written()
+		return true
+	})
+	if err == nil {
+		// the iterator may have returned early because of the cancellation
+		err = ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	if err = enc.end(out); err == nil {
+		err = out.Close()
+	}
+	sw.flush()
+	return err
+}
+
+// negotiateStream returns the stream encoder matching the Accept header and sets the Content-Type header to the returned media type.
+func (rd *Renderer) negotiateStream(w http.ResponseWriter, r *http.Request) (streamEncoder, string, error) {
+	accept := r.Header.Get("Accept")
+	list, err := server.ParseAccept(accept)
+	if err != nil {
+		return nil, "", errors.Wrap(err, fmt.Sprintf("failed to parse Accept header, invalid value: %s", accept))
+	}
+	if len(list) == 0 {
+		list = acceptAnything
+	}
+	for _, mt := range list {
+		if mt.Quality == 0 {
+			break
+		}
+		if _, ok := ndjsonMediaTypes[mt.Type]; ok {
+			w.Header().Set("Content-Type", mt.FullyQualifiedType)
+			return &ndjsonEncoder{}, mt.FullyQualifiedType, nil
+		}
+		if mt.IsAny || mt.Format == "*" {
+			mt.Format = rd.defaultFormat()
+		}
+		codec, ok := rd.codecs.lookup(mt.Format)
+		if !ok {
+			continue
+		}
+		var enc streamEncoder
+		switch mt.Format {
+		case "json":
+			enc = &jsonArrayEncoder{}
+		case "yaml":
+			enc = &yamlStreamEncoder{}
+		default:
+			continue
+		}
+		if mt.IsAny {
+			mt.FullyQualifiedType = codec.MediaType()
+		}
+		ft := strings.Replace(mt.FullyQualifiedType, "+*", "+"+mt.Format, 1)
+		w.Header().Set("Content-Type", ft)
+		return enc, ft, nil
+	}
+	return nil, "", errors.Errorf("no streamable content type found in Accept header: %s", accept)
+}
+
+// streamWriter flushes the response according to the stream configuration.
+type streamWriter struct {
+	w         http.ResponseWriter
+	cfg       streamConfig
+	count     int
+	lastFlush time.Time
+}
+
+func (sw *streamWriter) written() {
+	sw.count++
+	if sw.cfg.flushEvery > 0 && sw.count%sw.cfg.flushEvery == 0 {
+		sw.flush()
+		return
+	}
+	if sw.cfg.flushInterval > 0 && time.Since(sw.lastFlush) >= sw.cfg.flushInterval {
+		sw.flush()
+	}
+}
+
+func (sw *streamWriter) flush() {
+	sw.lastFlush = time.Now()
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type streamEncoder interface {
+	// format is the format of the output, see isText.
+	format() string
+	begin(w io.Writer) error
+	item(w io.Writer, v any) error
+	end(w io.Writer) error
+}
+
+type ndjsonEncoder struct{}
+
+func (*ndjsonEncoder) format() string        { return "json" }
+func (*ndjsonEncoder) begin(io.Writer) error { return nil }
+func (*ndjsonEncoder) end(io.Writer) error   { return nil }
+
+func (*ndjsonEncoder) item(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type jsonArrayEncoder struct {
+	started bool
+}
+
+func (*jsonArrayEncoder) format() string { return "json" }
+
+func (*jsonArrayEncoder) begin(w io.Writer) error {
+	_, err := io.WriteString(w, "[")
+	return err
+}
+
+func (e *jsonArrayEncoder) item(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if e.started {
+		if _, err = io.WriteString(w, ","); err != nil {
+			return err
+		}
+	}
+	e.started = true
+	_, err = w.Write(data)
+	return err
+}
+
+func (*jsonArrayEncoder) end(w io.Writer) error {
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
+
+type yamlStreamEncoder struct {
+	encoder *yaml.Encoder
+}
+
+func (*yamlStreamEncoder) format() string { return "yaml" }
+
+func (e *yamlStreamEncoder) begin(w io.Writer) error {
+	e.encoder = yaml.NewEncoder(w)
+	return nil
+}
+
+func (e *yamlStreamEncoder) item(_ io.Writer, v any) error {
+	return e.encoder.Encode(v)
+}
+
+func (e *yamlStreamEncoder) end(io.Writer) error {
+	return e.encoder.Close()
+}
@@ -0,0 +1,132 @@
+package renderer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/athosone/golib/pkg/server/renderer"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stream", func() {
+	var (
+		responseRecorder *httptest.ResponseRecorder
+		request          *http.Request
+		items            []testStruct
+	)
+	BeforeEach(func() {
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "https://example.com/", nil)
+		items = []testStruct{{Name: "first"}, {Name: "second"}}
+	})
+
+	Context("Streaming a single value", func() {
+		BeforeEach(func() {
+			request.Header.Set("Accept", "application/vnd.athosone.test+json; v=v1")
+		})
+		It("should commit headers and encode to the response", func() {
+			Expect(renderer.Stream(responseRecorder, request, http.StatusCreated, items[0])).To(Succeed())
+			Expect(responseRecorder.Code).To(Equal(http.StatusCreated))
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("application/vnd.athosone.test+json; v=v1"))
+			Expect(responseRecorder.Body.String()).To(Equal("{\"nameJson\":\"first\"}\n"))
+		})
+		It("should be used by a streaming renderer", func() {
+			rd := renderer.New()
+			rd.Streaming = true
+			Expect(rd.OK(responseRecorder, request, items[0])).To(Succeed())
+			Expect(responseRecorder.Body.String()).To(Equal("{\"nameJson\":\"first\"}\n"))
+		})
+	})
+
+	Context("Streaming a channel", func() {
+		var ch chan testStruct
+		BeforeEach(func() {
+			ch = make(chan testStruct)
+			go func() {
+				defer close(ch)
+				for _, item := range items {
+					ch <- item
+				}
+			}()
+		})
+		When("Request accepts ndjson", func() {
+			BeforeEach(func() {
+				request.Header.Set("Accept", renderer.NDJSONMediaType)
+			})
+			It("should write one document per line", func() {
+				Expect(renderer.StreamChannel(responseRecorder, request, http.StatusOK, ch)).To(Succeed())
+				Expect(responseRecorder.Header().Get("Content-Type")).To(Equal(renderer.NDJSONMediaType))
+				Expect(responseRecorder.Body.String()).To(Equal("{\"nameJson\":\"first\"}\n{\"nameJson\":\"second\"}\n"))
+				Expect(responseRecorder.Flushed).To(BeTrue())
+			})
+		})
+		When("Request accepts json", func() {
+			BeforeEach(func() {
+				request.Header.Set("Accept", "application/xml, application/json;q=0.5")
+			})
+			It("should write a json array", func() {
+				Expect(renderer.StreamChannel(responseRecorder, request, http.StatusOK, ch)).To(Succeed())
+				Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("application/json"))
+				Expect(responseRecorder.Body.String()).To(MatchJSON(`[{"nameJson":"first"},{"nameJson":"second"}]`))
+			})
+		})
+		When("Request accepts yaml", func() {
+			BeforeEach(func() {
+				request.Header.Set("Accept", "application/yaml")
+			})
+			It("should write a multi-document stream", func() {
+				Expect(renderer.StreamChannel(responseRecorder, request, http.StatusOK, ch)).To(Succeed())
+				Expect(responseRecorder.Body.String()).To(Equal("nameYaml: first\n---\nnameYaml: second\n"))
+			})
+		})
+	})
+
+	Context("Streaming an iterator", func() {
+		It("should write an empty json array when there is no item", func() {
+			request.Header.Set("Accept", "*/*")
+			Expect(renderer.StreamIterator(responseRecorder, request, http.StatusOK, renderer.FromSlice([]testStruct{}))).To(Succeed())
+			Expect(responseRecorder.Body.String()).To(Equal("[]\n"))
+		})
+		It("should write the default format without Accept header", func() {
+			Expect(renderer.StreamIterator(responseRecorder, request, http.StatusOK, renderer.FromSlice(items))).To(Succeed())
+			Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+			Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(responseRecorder.Body.String()).To(MatchJSON(`[{"nameJson":"first"},{"nameJson":"second"}]`))
+		})
+		It("should stop when the request is cancelled", func() {
+			request.Header.Set("Accept", renderer.NDJSONMediaType)
+			ctx, cancel := context.WithCancel(request.Context())
+			request = request.WithContext(ctx)
+			seq := func(yield func(testStruct) bool) {
+				yield(items[0])
+				cancel()
+				yield(items[1])
+			}
+			err := renderer.StreamIterator(responseRecorder, request, http.StatusOK, seq, renderer.FlushEvery(1))
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(responseRecorder.Body.String()).To(Equal("{\"nameJson\":\"first\"}\n"))
+		})
+		It("should stop waiting for the values of a channel when the request is cancelled", func() {
+			request.Header.Set("Accept", renderer.NDJSONMediaType)
+			ctx, cancel := context.WithCancel(request.Context())
+			request = request.WithContext(ctx)
+			idle := make(chan testStruct)
+			time.AfterFunc(50*time.Millisecond, cancel)
+
+			done := make(chan error)
+			go func() {
+				done <- renderer.StreamChannel(responseRecorder, request, http.StatusOK, idle)
+			}()
+			Eventually(done).Should(Receive(MatchError(context.Canceled)))
+			Expect(responseRecorder.Body.String()).To(BeEmpty())
+		})
+		It("should fail when no streamable format is accepted", func() {
+			request.Header.Set("Accept", "application/xml")
+			Expect(renderer.StreamIterator(responseRecorder, request, http.StatusOK, renderer.FromSlice(items))).NotTo(Succeed())
+			Expect(responseRecorder.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
@@ -0,0 +1,270 @@
+package renderer
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoConversion is returned when no chain of converters connects two versions.
+var ErrNoConversion = errors.New("no conversion between versions")
+
+type contextVersionsKey struct{}
+
+type converter func(any) (any, error)
+
+// Versions holds the converters between the versions of a resource, so that one handler working with
+// the canonical version can serve every version.
+// The version is read from a media type parameter, e.g.: application/vnd.athosone.book+json; v=v1beta2
+// Responses are converted to the version selected by the Accept header before being encoded
+// and request bodies are converted from the version of the Content-Type header to the type expected by the handler.
+// Conversions are chained when there is no direct converter (v2 -> v1beta2 -> v1beta1).
+type Versions struct {
+	// Param is the media type parameter holding the version, defaults to "v".
+	Param     string
+	canonical string
+	mu        sync.RWMutex
+	types     map[string]reflect.Type
+	edges     map[string]map[string]converter
+}
+
+// NewVersions creates the versions of a resource, canonical is the version handled by the handlers.
+func NewVersions(canonical string) *Versions {
+	return &Versions{
+		Param:     "v",
+		canonical: canonical,
+		types:     map[string]reflect.Type{},
+		edges:     map[string]map[string]converter{},
+	}
+}
+
+// Convert registers the converter from a version to another, the Go types of both versions are registered as well.
+func Convert[From, To any](vs *Versions, from, to string, fn func(From) (To, error)) *Versions {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.types[from] = reflect.TypeOf((*From)(nil)).Elem()
+	vs.types[to] = reflect.TypeOf((*To)(nil)).Elem()
+	if vs.edges[from] == nil {
+		vs.edges[from] = map[string]converter{}
+	}
+	vs.edges[from][to] = func(v any) (any, error) {
+		f, ok := v.(From)
+		if !ok {
+			return nil, errors.Errorf("cannot convert %T from version %s", v, from)
+		}
+		return fn(f)
+	}
+	return vs
+}
+
+// Canonical returns the version handled by the handlers.
+func (vs *Versions) Canonical() string {
+	return vs.canonical
+}
+
+// ConvertValue converts v from a version to another.
+func (vs *Versions) ConvertValue(v any, from, to string) (any, error) {
+	if from == to {
+		return v, nil
+	}
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	path := vs.path(from, to)
+	if path == nil {
+		return nil, errors.Wrapf(ErrNoConversion, "from %s to %s", from, to)
+	}
+	var err error
+	for _, convert := range path {
+		if v, err = convert(v); err != nil {
+			return nil, errors.Wrapf(err, "failed to convert from %s to %s", from, to)
+		}
+	}
+	return v, nil
+}
+
+// path returns the shortest chain of converters between two versions.
+func (vs *Versions) path(from, to string) []converter {
+	type step struct {
+		version string
+		chain   []converter
+	}
+	visited := map[string]bool{from: true}
+	queue := []step{{version: from}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for next, convert := range vs.edges[current.version] {
+			if visited[next] {
+				continue
+			}
+			chain := append(append([]converter{}, current.chain...), convert)
+			if next == to {
+				return chain
+			}
+			visited[next] = true
+			queue = append(queue, step{version: next, chain: chain})
+		}
+	}
+	return nil
+}
+
+// lookup returns the version registered for the type.
+// Versions sharing the same type are ambiguous, the canonical one is preferred.
+func (vs *Versions) lookup(t reflect.Type) (string, bool) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	if ct, ok := vs.types[vs.canonical]; ok && ct == t {
+		return vs.canonical, true
+	}
+	for version, vt := range vs.types {
+		if vt == t {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// convertResponse converts v to the version. Pointers to a registered type are dereferenced
+// and the items of slices and arrays are converted one by one.
+// Values whose type is not registered, e.g. maps or []string, are returned unchanged.
+func (vs *Versions) convertResponse(v any, to string) (any, error) {
+	rv := reflect.ValueOf(v)
+	if from, ok := vs.lookup(rv.Type()); ok {
+		return vs.ConvertValue(v, from, to)
+	}
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return v, nil
+		}
+		return vs.convertResponse(rv.Elem().Interface(), to)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() || !vs.holdsVersions(rv.Type().Elem()) {
+			return v, nil
+		}
+		return vs.convertItems(rv, to)
+	}
+	return v, nil
+}
+
+// holdsVersions is true when values of the type may hold a registered type.
+func (vs *Versions) holdsVersions(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		if _, ok := vs.lookup(t); ok {
+			return true
+		}
+		return vs.holdsVersions(t.Elem())
+	}
+	_, ok := vs.lookup(t)
+	return ok
+}
+
+// convertItems converts the items of the slice into a slice of the type of the version,
+// or a []any when the converted items do not share that type.
+func (vs *Versions) convertItems(items reflect.Value, to string) (any, error) {
+	converted := make([]any, items.Len())
+	for i := range converted {
+		item, err := vs.convertResponse(items.Index(i).Interface(), to)
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = item
+	}
+	t, ok := vs.TypeOf(to)
+	if !ok {
+		return converted, nil
+	}
+	typed := reflect.MakeSlice(reflect.SliceOf(t), len(converted), len(converted))
+	for i, item := range converted {
+		if item == nil || reflect.TypeOf(item) != t {
+			return converted, nil
+		}
+		typed.Index(i).Set(reflect.ValueOf(item))
+	}
+	return typed.Interface(), nil
+}
+
+// TypeOf returns the Go type registered for the version.
+func (vs *Versions) TypeOf(version string) (reflect.Type, bool) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	t, ok := vs.types[version]
+	return t, ok
+}
+
+// MediaTypeVersion returns the version parameter of the media type, empty when none.
+func (vs *Versions) MediaTypeVersion(mediaType string) string {
+	_, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return ""
+	}
+	return params[vs.Param]
+}
+
+// NewVersionsContext returns a context carrying the versions used by the renderer.
+func NewVersionsContext(parent context.Context, vs *Versions) context.Context {
+	return context.WithValue(parent, contextVersionsKey{}, vs)
+}
+
+// VersionsFromContext returns the versions carried by the context or nil.
+func VersionsFromContext(ctx context.Context) *Versions {
+	vs, _ := ctx.Value(contextVersionsKey{}).(*Versions)
+	return vs
+}
+
+// toResponseVersion converts v to the version of the media type selected for the response.
+func toResponseVersion(r *http.Request, mediaType string, v any) (any, error) {
+	vs := VersionsFromContext(r.Context())
+	if vs == nil || v == nil {
+		return v, nil
+	}
+	version := vs.MediaTypeVersion(mediaType)
+	if version == "" {
+		return v, nil
+	}
+	return vs.convertResponse(v, version)
+}
+
+// decodeVersion decodes the body sent in the version of the Content-Type header and converts it into v.
+// It returns false when no conversion is needed.
+func decodeVersion(r *http.Request, codec Codec, body io.Reader, v any) (bool, error) {
+	vs := VersionsFromContext(r.Context())
+	if vs == nil {
+		return false, nil
+	}
+	version := vs.MediaTypeVersion(r.Header.Get("Content-Type"))
+	target := reflect.ValueOf(v)
+	if version == "" || target.Kind() != reflect.Pointer || target.IsNil() {
+		return false, nil
+	}
+	to, ok := vs.lookup(target.Elem().Type())
+	if !ok || version == to {
+		return false, nil
+	}
+	sourceType, ok := vs.TypeOf(version)
+	if !ok {
+		return false, nil
+	}
+	source := reflect.New(sourceType)
+	if err := codec.Decode(body, source.Interface()); err != nil {
+		return true, err
+	}
+	converted, err := vs.ConvertValue(source.Elem().Interface(), version, to)
+	if err != nil {
+		return true, err
+	}
+	cv := reflect.ValueOf(converted)
+	if !cv.Type().AssignableTo(target.Elem().Type()) {
+		return true, errors.Wrapf(ErrNoConversion, "cannot assign %s to %s", cv.Type(), target.Elem().Type())
+	}
+	target.Elem().Set(cv)
+	return true, nil
+}
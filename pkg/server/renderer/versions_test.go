@@ -0,0 +1,157 @@
+package renderer_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/athosone/golib/pkg/server/renderer"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type bookV1 struct {
+	Title string `json:"title"`
+}
+
+type bookV2 struct {
+	Title  string `json:"title"`
+	Author string `json:"author"`
+}
+
+type bookV3 struct {
+	Name   string `json:"name"`
+	Author string `json:"author"`
+}
+
+const bookMediaType = "application/vnd.athosone.book+json"
+
+var _ = Describe("Versions", func() {
+	var (
+		versions *renderer.Versions
+		request  *http.Request
+		recorder *httptest.ResponseRecorder
+	)
+	BeforeEach(func() {
+		versions = renderer.NewVersions("v3")
+		renderer.Convert(versions, "v3", "v2", func(b bookV3) (bookV2, error) {
+			return bookV2{Title: b.Name, Author: b.Author}, nil
+		})
+		renderer.Convert(versions, "v2", "v1", func(b bookV2) (bookV1, error) {
+			return bookV1{Title: b.Title}, nil
+		})
+		renderer.Convert(versions, "v1", "v2", func(b bookV1) (bookV2, error) {
+			return bookV2{Title: b.Title, Author: "unknown"}, nil
+		})
+		renderer.Convert(versions, "v2", "v3", func(b bookV2) (bookV3, error) {
+			return bookV3{Name: b.Title, Author: b.Author}, nil
+		})
+		recorder = httptest.NewRecorder()
+	})
+	withVersions := func(r *http.Request) *http.Request {
+		return r.WithContext(renderer.NewVersionsContext(r.Context(), versions))
+	}
+
+	It("should chain converters", func() {
+		v, err := versions.ConvertValue(bookV3{Name: "Dune", Author: "Herbert"}, "v3", "v1")
+		Expect(err).To(BeNil())
+		Expect(v).To(Equal(bookV1{Title: "Dune"}))
+	})
+	It("should fail when versions are not connected", func() {
+		_, err := versions.ConvertValue(bookV3{}, "v3", "v0")
+		Expect(errors.Is(err, renderer.ErrNoConversion)).To(BeTrue())
+	})
+
+	When("Rendering a response", func() {
+		BeforeEach(func() {
+			request, _ = http.NewRequest("GET", "https://example.com/", nil)
+			request = withVersions(request)
+		})
+		It("should convert the canonical version to the accepted one", func() {
+			request.Header.Set("Accept", bookMediaType+"; v=v1")
+			err := renderer.OK(recorder, request, bookV3{Name: "Dune", Author: "Herbert"})
+			Expect(err).To(BeNil())
+			Expect(recorder.Body.String()).To(MatchJSON(`{"title":"Dune"}`))
+		})
+		It("should not convert without version parameter", func() {
+			request.Header.Set("Accept", bookMediaType)
+			err := renderer.OK(recorder, request, bookV3{Name: "Dune", Author: "Herbert"})
+			Expect(err).To(BeNil())
+			Expect(recorder.Body.String()).To(MatchJSON(`{"name":"Dune","author":"Herbert"}`))
+		})
+		It("should convert every streamed item", func() {
+			request.Header.Set("Accept", bookMediaType+"; v=v2")
+			books := []bookV3{{Name: "Dune", Author: "Herbert"}, {Name: "Emma", Author: "Austen"}}
+			err := renderer.StreamIterator(recorder, request, http.StatusOK, renderer.FromSlice(books))
+			Expect(err).To(BeNil())
+			Expect(recorder.Body.String()).To(MatchJSON(`[{"title":"Dune","author":"Herbert"},{"title":"Emma","author":"Austen"}]`))
+		})
+		It("should convert a pointer to the canonical version", func() {
+			request.Header.Set("Accept", bookMediaType+"; v=v1")
+			err := renderer.OK(recorder, request, &bookV3{Name: "Dune", Author: "Herbert"})
+			Expect(err).To(BeNil())
+			Expect(recorder.Body.String()).To(MatchJSON(`{"title":"Dune"}`))
+		})
+		It("should convert every item of a slice", func() {
+			request.Header.Set("Accept", bookMediaType+"; v=v1")
+			err := renderer.OK(recorder, request, []bookV3{{Name: "Dune"}, {Name: "Emma"}})
+			Expect(err).To(BeNil())
+			Expect(recorder.Body.String()).To(MatchJSON(`[{"title":"Dune"},{"title":"Emma"}]`))
+		})
+		It("should convert every streamed pointer", func() {
+			request.Header.Set("Accept", bookMediaType+"; v=v1")
+			books := []*bookV3{{Name: "Dune"}, nil}
+			err := renderer.StreamIterator(recorder, request, http.StatusOK, renderer.FromSlice(books))
+			Expect(err).To(BeNil())
+			Expect(recorder.Body.String()).To(MatchJSON(`[{"title":"Dune"},null]`))
+		})
+		It("should not convert a map", func() {
+			request.Header.Set("Accept", bookMediaType+"; v=v1")
+			err := renderer.OK(recorder, request, map[string]int{"count": 2})
+			Expect(err).To(BeNil())
+			Expect(recorder.Body.String()).To(MatchJSON(`{"count":2}`))
+		})
+		It("should not convert a slice of scalars", func() {
+			request.Header.Set("Accept", bookMediaType+"; v=v1")
+			err := renderer.OK(recorder, request, []string{"Dune", "Emma"})
+			Expect(err).To(BeNil())
+			Expect(recorder.Body.String()).To(MatchJSON(`["Dune","Emma"]`))
+		})
+		It("should fail for an unknown version", func() {
+			request.Header.Set("Accept", bookMediaType+"; v=v0")
+			err := renderer.OK(recorder, request, bookV3{Name: "Dune"})
+			Expect(errors.Is(err, renderer.ErrNoConversion)).To(BeTrue())
+		})
+	})
+
+	When("Decoding a request", func() {
+		var decoded bookV3
+		BeforeEach(func() {
+			decoded = bookV3{}
+			request, _ = http.NewRequest("POST", "https://example.com/", strings.NewReader(`{"title":"Dune"}`))
+			request = withVersions(request)
+		})
+		It("should convert the body to the version expected by the handler", func() {
+			request.Header.Set("Content-Type", bookMediaType+"; v=v1")
+			err := renderer.Decode(request, &decoded)
+			Expect(err).To(BeNil())
+			Expect(decoded).To(Equal(bookV3{Name: "Dune", Author: "unknown"}))
+		})
+		It("should decode directly a type without version", func() {
+			request.Header.Set("Content-Type", bookMediaType+"; v=v1")
+			var fields map[string]string
+			err := renderer.Decode(request, &fields)
+			Expect(err).To(BeNil())
+			Expect(fields).To(Equal(map[string]string{"title": "Dune"}))
+		})
+		It("should decode directly the canonical version", func() {
+			request, _ = http.NewRequest("POST", "https://example.com/", strings.NewReader(`{"name":"Dune"}`))
+			request = withVersions(request)
+			request.Header.Set("Content-Type", bookMediaType+"; v=v3")
+			err := renderer.Decode(request, &decoded)
+			Expect(err).To(BeNil())
+			Expect(decoded).To(Equal(bookV3{Name: "Dune"}))
+		})
+	})
+})
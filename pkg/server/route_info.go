@@ -0,0 +1,28 @@
+package server
+
+import "context"
+
+type contextRouteInfoKey struct{}
+
+// RouteInfo describes the route chosen for a request. It is placed in the context by the middlewares running
+// before the router (e.g. to label metrics) and filled by the routers supporting it, such as routing.GRouter.
+type RouteInfo struct {
+	// Pattern is the path pattern of the route relative to the outermost router, e.g.: /api/books/{id}
+	Pattern string
+	// MediaType is the media type of the route selected for the response with its parameters,
+	// e.g.: application/vnd.athosone.book+json; v=v2
+	// It holds the media type registered by the route rather than the one sent by the client.
+	MediaType string
+}
+
+// NewRouteInfoContext returns a context carrying an empty RouteInfo to be filled by the router.
+func NewRouteInfoContext(parent context.Context) (context.Context, *RouteInfo) {
+	info := &RouteInfo{}
+	return context.WithValue(parent, contextRouteInfoKey{}, info), info
+}
+
+// RouteInfoFromContext returns the RouteInfo of the context, nil when none.
+func RouteInfoFromContext(ctx context.Context) *RouteInfo {
+	info, _ := ctx.Value(contextRouteInfoKey{}).(*RouteInfo)
+	return info
+}
@@ -0,0 +1,159 @@
+package routing
+
+import (
+	"container/heap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/athosone/golib/pkg/server"
+)
+
+// legacyServeHTTP is the negotiation as it was before the route table:
+// headers are parsed into heaps and every route is matched for every media type.
+// It is kept as the baseline of the benchmarks.
+func (gr *GRouter) legacyServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get(HeaderAccept)
+	contentType := r.Header.Get(HeaderContentType)
+
+	acceptHeap, err := server.ParseMediaType(accept)
+	if err != nil {
+		gr.notAcceptable(w, r)
+		return
+	}
+	consumeHeap, err := server.ParseMediaType(contentType)
+	if err != nil {
+		gr.notAcceptable(w, r)
+		return
+	}
+	routes := []*Route{}
+	for _, route := range gr.routes {
+		if route.isMethodMatch(r.Method) {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) == 0 {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	mRouter := map[*Route]struct{}{}
+	for len(consumeHeap) > 0 {
+		consumeContentType := heap.Pop(&consumeHeap).(server.ContentMediaType)
+		for _, route := range routes {
+			if route.IsConsumeMatch(r, consumeContentType) {
+				if len(acceptHeap) == 0 || acceptHeap[0].IsAny {
+					if len(route.produce) > 0 {
+						r.Header.Set(HeaderAccept, route.produce[0].getFullyQualifiedType())
+					}
+					route.ServeHTTP(w, r)
+					return
+				}
+				mRouter[route] = struct{}{}
+			}
+		}
+	}
+	if contentType != "" && len(mRouter) == 0 {
+		gr.negotiate(w, r)
+		return
+	}
+	for len(acceptHeap) > 0 {
+		acceptContentType := heap.Pop(&acceptHeap).(server.ContentMediaType)
+		for _, route := range routes {
+			if route.IsProduceMatch(r, acceptContentType) {
+				_, hasRoutePair := mRouter[route]
+				if contentType == "" || hasRoutePair {
+					r.Header.Set(HeaderAccept, acceptContentType.FullyQualifiedType)
+					if acceptContentType.IsAny && len(route.produce) > 0 {
+						r.Header.Set(HeaderAccept, route.produce[0].getFullyQualifiedType())
+					}
+					route.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+	}
+	gr.negotiate(w, r)
+}
+
+type discardResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardResponseWriter) WriteHeader(status int)      { d.status = status }
+
+func benchmarkRouter() *GRouter {
+	gr := NewRouter()
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Route", name)
+		}
+	}
+	for _, version := range []string{"v1alpha1", "v1beta1", "v1beta2", "v1", "v2", "v3"} {
+		gr.Get(handler("get-" + version)).Produce("application/vnd.athosone.book+*; v=" + version)
+		gr.Post(handler("post-" + version)).
+			Consume("application/vnd.athosone.book.add+json; v=" + version).
+			Produce("application/vnd.athosone.book+json; v=" + version)
+	}
+	gr.Get(handler("get-json")).Produce("application/json").SetDefault()
+	return gr
+}
+
+var benchmarkRequests = []struct {
+	name, method, accept, contentType string
+}{
+	{"exact", http.MethodGet, "application/vnd.athosone.book+json; v=v3", ""},
+	{"wildcard", http.MethodGet, "*/*", ""},
+	{"quality", http.MethodGet, "application/xml;q=0.5, application/vnd.athosone.book+yaml; v=v1beta2;q=0.9, text/html", ""},
+	{"consume", http.MethodPost, "application/vnd.athosone.book+json; v=v2", "application/vnd.athosone.book.add+json; v=v2"},
+	{"not-acceptable", http.MethodGet, "text/html", ""},
+}
+
+func newBenchmarkRequest(method, accept, contentType string) *http.Request {
+	req := httptest.NewRequest(method, "/books", nil)
+	req.Header.Set(HeaderAccept, accept)
+	if contentType != "" {
+		req.Header.Set(HeaderContentType, contentType)
+	}
+	return req
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	impls := []struct {
+		name  string
+		serve func(gr *GRouter, w http.ResponseWriter, r *http.Request)
+	}{
+		{"legacy", (*GRouter).legacyServeHTTP},
+		{"table", (*GRouter).ServeHTTP},
+	}
+	gr := benchmarkRouter()
+	for _, br := range benchmarkRequests {
+		// both implementations must select the same route
+		var selected []string
+		for _, impl := range impls {
+			w := &discardResponseWriter{header: http.Header{}}
+			impl.serve(gr, w, newBenchmarkRequest(br.method, br.accept, br.contentType))
+			selected = append(selected, w.header.Get("X-Route")+"/"+strings.TrimSpace(http.StatusText(w.status)))
+		}
+		if selected[0] != selected[1] {
+			b.Fatalf("%s: legacy selected %s, table selected %s", br.name, selected[0], selected[1])
+		}
+
+		for _, impl := range impls {
+			b.Run(br.name+"/"+impl.name, func(b *testing.B) {
+				req := newBenchmarkRequest(br.method, br.accept, br.contentType)
+				w := &discardResponseWriter{header: http.Header{}}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					req.Header[HeaderAccept][0] = br.accept
+					impl.serve(gr, w, req)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	glogger "github.com/athosone/golib/pkg/logger"
+)
+
+const (
+	HeaderDeprecation = "Deprecation"
+	HeaderSunset      = "Sunset"
+	HeaderLink        = "Link"
+)
+
+// DeprecationInfo describes the deprecation of the media type selected for a request.
+type DeprecationInfo struct {
+	Method    string
+	MediaType string
+	Since     time.Time
+	Sunset    time.Time
+	Successor string
+}
+
+// DeprecationObserver is called every time a deprecated media type is served, e.g. to count the usage.
+type DeprecationObserver func(r *http.Request, info DeprecationInfo)
+
+type lifecycle struct {
+	mediaTypes Patterns
+	since      time.Time
+	sunset     time.Time
+	successor  string
+	gone       bool
+}
+
+// DeprecationOption configures Deprecated and Sunset.
+type DeprecationOption func(*lifecycle)
+
+// ForMediaTypes restricts the deprecation to some of the media types produced by the route.
+func ForMediaTypes(mediaTypes ...string) DeprecationOption {
+	return func(l *lifecycle) {
+		for _, mediaType := range mediaTypes {
+			l.mediaTypes = append(l.mediaTypes, NewPattern(formatMediaType(mediaType)))
+		}
+	}
+}
+
+// GoneAfterSunset answers 410 Gone once the sunset date is passed instead of serving the route.
+func GoneAfterSunset() DeprecationOption {
+	return func(l *lifecycle) {
+		l.gone = true
+	}
+}
+
+// Deprecated marks the route as deprecated since the date: responses carry the Deprecation header (RFC 9745)
+// and, when successor is not empty, a Link header with rel="successor-version".
+func (r *Route) Deprecated(since time.Time, successor string, opts ...DeprecationOption) *Route {
+	l := &lifecycle{since: since, successor: successor}
+	for _, opt := range opts {
+		opt(l)
+	}
+	r.lifecycles = append(r.lifecycles, l)
+	return r
+}
+
+// Sunset announces the date after which the route will not be served anymore with the Sunset header (RFC 8594).
+func (r *Route) Sunset(at time.Time, opts ...DeprecationOption) *Route {
+	l := &lifecycle{sunset: at}
+	for _, opt := range opts {
+		opt(l)
+	}
+	r.lifecycles = append(r.lifecycles, l)
+	return r
+}
+
+// deprecation merges the lifecycles matching the media type,
+// it returns nil when the media type is not deprecated.
+func (r *Route) deprecation(mediaType string) *lifecycle {
+	var merged *lifecycle
+	for _, l := range r.lifecycles {
+		if len(l.mediaTypes) > 0 && !match(mediaType, l.mediaTypes) {
+			continue
+		}
+		if merged == nil {
+			merged = &lifecycle{}
+		}
+		if !l.since.IsZero() && (merged.since.IsZero() || l.since.Before(merged.since)) {
+			merged.since = l.since
+		}
+		if !l.sunset.IsZero() && (merged.sunset.IsZero() || l.sunset.Before(merged.sunset)) {
+			merged.sunset = l.sunset
+		}
+		if merged.successor == "" {
+			merged.successor = l.successor
+		}
+		merged.gone = merged.gone || l.gone
+	}
+	return merged
+}
+
+// isDeprecated is true when the whole route is deprecated.
+func (r *Route) isDeprecated() bool {
+	for _, l := range r.lifecycles {
+		if len(l.mediaTypes) == 0 && !l.since.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// serveDeprecated writes the deprecation headers of the media type selected for the request.
+// It returns false when the request must not be served because the sunset date is passed.
+func (gr *GRouter) serveDeprecated(route *Route, w http.ResponseWriter, r *http.Request) bool {
+	mediaType := r.Header.Get(HeaderAccept)
+	l := route.deprecation(mediaType)
+	if l == nil {
+		return true
+	}
+	if !l.since.IsZero() {
+		w.Header().Set(HeaderDeprecation, fmt.Sprintf("@%d", l.since.Unix()))
+	}
+	if !l.sunset.IsZero() {
+		w.Header().Set(HeaderSunset, l.sunset.UTC().Format(http.TimeFormat))
+	}
+	if l.successor != "" {
+		w.Header().Add(HeaderLink, fmt.Sprintf(`<%s>; rel="successor-version"`, l.successor))
+	}
+	info := DeprecationInfo{
+		Method:    r.Method,
+		MediaType: mediaType,
+		Since:     l.since,
+		Sunset:    l.sunset,
+		Successor: l.successor,
+	}
+	glogger.LoggerFromContextOrDefault(r.Context()).Infow("deprecated media type requested",
+		"method", info.Method,
+		"media_type", info.MediaType,
+		"deprecated_since", info.Since,
+		"sunset", info.Sunset,
+	)
+	if gr.deprecationObserver != nil {
+		gr.deprecationObserver(r, info)
+	}
+	if l.gone && !l.sunset.IsZero() && time.Now().After(l.sunset) {
+		gr.fail(w, r, http.StatusGone, "", nil)
+		return false
+	}
+	return true
+}
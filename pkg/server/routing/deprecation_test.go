@@ -0,0 +1,98 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+var _ = Describe("Deprecation", func() {
+	const (
+		v1beta1Type = "application/vnd.athosone.book+*; v=v1beta1"
+		v1beta2Type = "application/vnd.athosone.book+*; v=v1beta2"
+		v2Type      = "application/vnd.athosone.book+json; v=v2"
+	)
+	var (
+		gRouter          *routing.GRouter
+		responseRecorder *httptest.ResponseRecorder
+		observed         []routing.DeprecationInfo
+		route            *routing.Route
+		since            = time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	)
+	serve := func(accept string) {
+		req, _ := http.NewRequest(http.MethodGet, "/books", nil)
+		req.Header.Set("Accept", accept)
+		gRouter.ServeHTTP(responseRecorder, req)
+	}
+	BeforeEach(func() {
+		observed = nil
+		responseRecorder = httptest.NewRecorder()
+		gRouter = routing.NewRouter(routing.WithDeprecationObserver(func(r *http.Request, info routing.DeprecationInfo) {
+			observed = append(observed, info)
+		}))
+		route = gRouter.Get(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}).Produce(v1beta1Type, v1beta2Type)
+		gRouter.Get(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}).Produce(v2Type)
+	})
+
+	When("a media type is deprecated", func() {
+		var sunset time.Time
+		BeforeEach(func() {
+			sunset = time.Now().Add(24 * time.Hour).Truncate(time.Second)
+			route.Deprecated(since, "/books", routing.ForMediaTypes(v1beta1Type)).
+				Sunset(sunset, routing.ForMediaTypes(v1beta1Type))
+		})
+		It("should add the deprecation headers when selected", func() {
+			serve("application/vnd.athosone.book+json; v=v1beta1")
+			Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+			Expect(responseRecorder.Header().Get("Deprecation")).To(Equal("@1654041600"))
+			Expect(responseRecorder.Header().Get("Sunset")).To(Equal(sunset.UTC().Format(http.TimeFormat)))
+			Expect(responseRecorder.Header().Get("Link")).To(Equal(`</books>; rel="successor-version"`))
+			Expect(observed).To(HaveLen(1))
+			Expect(observed[0].MediaType).To(Equal("application/vnd.athosone.book+json; v=v1beta1"))
+			Expect(observed[0].Since).To(Equal(since))
+		})
+		It("should not add the headers for the other media types", func() {
+			serve("application/vnd.athosone.book+json; v=v1beta2")
+			Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+			Expect(responseRecorder.Header().Get("Deprecation")).To(BeEmpty())
+			Expect(observed).To(BeEmpty())
+		})
+	})
+	When("the whole route is deprecated", func() {
+		BeforeEach(func() {
+			route.Deprecated(since, "")
+		})
+		It("should add the headers for every media type", func() {
+			serve("application/vnd.athosone.book+yaml; v=v1beta2")
+			Expect(responseRecorder.Header().Get("Deprecation")).To(Equal("@1654041600"))
+			Expect(responseRecorder.Header().Get("Link")).To(BeEmpty())
+		})
+		It("should be flagged in the routes", func() {
+			Expect(gRouter.Routes()[0].Deprecated).To(BeTrue())
+			Expect(gRouter.Routes()[1].Deprecated).To(BeFalse())
+		})
+	})
+	When("the sunset date is passed", func() {
+		BeforeEach(func() {
+			route.Deprecated(since, "/books").Sunset(since.Add(time.Hour), routing.GoneAfterSunset())
+		})
+		It("should return 410", func() {
+			serve("application/vnd.athosone.book+json; v=v1beta1")
+			Expect(responseRecorder.Code).To(Equal(http.StatusGone))
+			Expect(responseRecorder.Header().Get("Link")).To(Equal(`</books>; rel="successor-version"`))
+		})
+		It("should still serve the successor", func() {
+			serve(v2Type)
+			Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+		})
+	})
+})
@@ -0,0 +1,109 @@
+package routing_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+var _ = Describe("Methods", func() {
+	const (
+		v1Type = "application/vnd.athosone.innersource+json; v=1"
+		v2Type = "application/vnd.athosone.innersource+json; v=2"
+	)
+	var (
+		gRouter          *routing.GRouter
+		responseRecorder *httptest.ResponseRecorder
+	)
+	serve := func(method string) {
+		req, _ := http.NewRequest(method, "/", nil)
+		req.Header.Set("Accept", v1Type)
+		gRouter.ServeHTTP(responseRecorder, req)
+	}
+	BeforeEach(func() {
+		responseRecorder = httptest.NewRecorder()
+		gRouter = routing.NewRouter()
+		gRouter.Get(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Method", r.Method)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("body"))
+		}).Produce(v1Type)
+		gRouter.Post(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}).Consume(v1Type, v2Type)
+		gRouter.Patch(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}).Consume(v2Type)
+	})
+
+	It("should list the allowed methods", func() {
+		Expect(gRouter.AllowedMethods()).To(Equal([]string{"GET", "HEAD", "POST", "PATCH", "OPTIONS"}))
+	})
+	When("requesting a method without route", func() {
+		It("should return 405 with the Allow header", func() {
+			serve(http.MethodDelete)
+			Expect(responseRecorder.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(responseRecorder.Header().Get("Allow")).To(Equal("GET, HEAD, POST, PATCH, OPTIONS"))
+		})
+	})
+	When("requesting OPTIONS", func() {
+		It("should answer with the allowed methods and accepted media types", func() {
+			serve(http.MethodOptions)
+			Expect(responseRecorder.Code).To(Equal(http.StatusNoContent))
+			Expect(responseRecorder.Header().Get("Allow")).To(Equal("GET, HEAD, POST, PATCH, OPTIONS"))
+			Expect(responseRecorder.Header().Get("Accept-Post")).To(Equal(fmt.Sprintf("%s, %s", v1Type, v2Type)))
+			Expect(responseRecorder.Header().Get("Accept-Patch")).To(Equal(v2Type))
+		})
+		It("should use the OPTIONS route when registered", func() {
+			gRouter.Options(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}).Produce(v1Type)
+			serve(http.MethodOptions)
+			Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+		})
+	})
+	When("requesting with a long Accept header", func() {
+		It("should negotiate like with a short one", func() {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", strings.Repeat("text/plain; q=0.1, ", 20)+v1Type)
+			gRouter.ServeHTTP(responseRecorder, req)
+			Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+		})
+	})
+	When("requesting HEAD", func() {
+		It("should be served by the GET route without body", func() {
+			serve(http.MethodHead)
+			Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+			Expect(responseRecorder.Header().Get("X-Method")).To(Equal(http.MethodHead))
+			Expect(responseRecorder.Body.Len()).To(BeZero())
+		})
+		It("should keep the writer flushable", func() {
+			var unwrapped http.ResponseWriter
+			gRouter.Get(func(w http.ResponseWriter, r *http.Request) {
+				flusher, ok := w.(http.Flusher)
+				Expect(ok).To(BeTrue())
+				_, _ = w.Write([]byte("body"))
+				flusher.Flush()
+				unwrapped = w.(interface{ Unwrap() http.ResponseWriter }).Unwrap()
+			}).Produce(v2Type)
+			req, _ := http.NewRequest(http.MethodHead, "/", nil)
+			req.Header.Set("Accept", v2Type)
+			gRouter.ServeHTTP(responseRecorder, req)
+			Expect(responseRecorder.Flushed).To(BeTrue())
+			Expect(responseRecorder.Body.Len()).To(BeZero())
+			Expect(unwrapped).To(BeIdenticalTo(responseRecorder))
+		})
+		It("should negotiate like GET", func() {
+			req, _ := http.NewRequest(http.MethodHead, "/", nil)
+			req.Header.Set("Accept", v2Type)
+			gRouter.ServeHTTP(responseRecorder, req)
+			Expect(responseRecorder.Code).To(Equal(http.StatusNotAcceptable))
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package routing
+
+import (
+	"github.com/athosone/golib/pkg/server/renderer"
+	"github.com/athosone/golib/pkg/telemetry"
+)
+
+// RouterOption configures a GRouter.
+type RouterOption func(*GRouter)
+
+// WithRenderer injects the renderer in the context of the requests dispatched by the router,
+// the package level functions of the renderer package will then use its codecs.
+func WithRenderer(rd *renderer.Renderer) RouterOption {
+	return func(gr *GRouter) {
+		gr.renderer = rd
+	}
+}
+
+// WithProblemResponses makes the router answer its own negotiation failures (405, 406 and 415)
+// with RFC 7807 problem documents listing the acceptable media types (or the allowed methods for 405).
+func WithProblemResponses() RouterOption {
+	return func(gr *GRouter) {
+		gr.problemResponses = true
+	}
+}
+
+// WithDeprecationObserver calls the observer every time a deprecated media type is served.
+func WithDeprecationObserver(observer DeprecationObserver) RouterOption {
+	return func(gr *GRouter) {
+		gr.deprecationObserver = observer
+	}
+}
+
+// WithTelemetry traces the dispatch of the requests with OpenTelemetry, see telemetry.New for the providers.
+// Each request served by the router gets a span annotated with the chosen route and the negotiated media types.
+func WithTelemetry(opts ...telemetry.Option) RouterOption {
+	return func(gr *GRouter) {
+		gr.telemetry = telemetry.New(opts...)
+	}
+}
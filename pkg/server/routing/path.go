@@ -0,0 +1,215 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type contextRouteKey struct{}
+
+// routeContext holds the part of the path left to match and the parameters captured so far.
+type routeContext struct {
+	path   string
+	params []param
+	// pattern is the concatenation of the patterns of the sub-routers matched so far
+	pattern string
+}
+
+type param struct {
+	name, value string
+}
+
+// URLParam returns the value of the named path parameter captured by the GRouter,
+// the parameter matched by a trailing wildcard is named "*".
+// It returns an empty string when the parameter does not exist.
+func URLParam(r *http.Request, name string) string {
+	rctx, ok := r.Context().Value(contextRouteKey{}).(*routeContext)
+	if !ok {
+		return ""
+	}
+	for i := len(rctx.params) - 1; i >= 0; i-- {
+		if rctx.params[i].name == name {
+			return rctx.params[i].value
+		}
+	}
+	return ""
+}
+
+// Route creates a sub-router handling the requests whose path starts with the pattern and calls fn to configure it.
+// Patterns are made of segments separated by "/":
+//   - literal segments must match exactly: /books
+//   - named parameters match any segment: /books/{id}, see URLParam
+//   - a trailing wildcard matches the rest of the path: /static/*
+//
+// Calling Route twice with the same pattern configures the same sub-router.
+// The sub-router inherits the options of its parent.
+func (gr *GRouter) Route(pattern string, fn func(r *GRouter)) *GRouter {
+	p := compilePath(pattern)
+	sub := gr.subRouter(p.pattern)
+	if sub == nil {
+		sub = &GRouter{
+			routes:              []*Route{},
+			problemResponses:    gr.problemResponses,
+			deprecationObserver: gr.deprecationObserver,
+			telemetry:           gr.telemetry,
+		}
+		gr.Mount(pattern, sub)
+	}
+	if fn != nil {
+		fn(sub)
+	}
+	return sub
+}
+
+// Mount attaches an existing router at the pattern, see Route for the pattern syntax.
+func (gr *GRouter) Mount(pattern string, sub *GRouter) {
+	sub.path = compilePath(pattern)
+	gr.subRouters = append(gr.subRouters, sub)
+}
+
+func (gr *GRouter) subRouter(pattern string) *GRouter {
+	for _, sub := range gr.subRouters {
+		if sub.path.pattern == pattern {
+			return sub
+		}
+	}
+	return nil
+}
+
+// routePath dispatches the request to the most specific sub-router matching the path.
+// It returns false when the request must be handled by the routes of the router itself.
+func (gr *GRouter) routePath(w http.ResponseWriter, r *http.Request) bool {
+	rctx, ok := r.Context().Value(contextRouteKey{}).(*routeContext)
+	if !ok {
+		if len(gr.subRouters) == 0 {
+			// not taking part in path routing, e.g. mounted in another framework
+			return false
+		}
+		rctx = &routeContext{path: r.URL.Path}
+	}
+
+	best, bestRest, bestVals := gr.matchSubRouter(rctx.path)
+	if best != nil {
+		child := &routeContext{
+			path:    bestRest,
+			params:  append(append(make([]param, 0, len(rctx.params)+len(bestVals)), rctx.params...), bestVals...),
+			pattern: strings.TrimSuffix(rctx.pattern, "/") + best.path.pattern,
+		}
+		best.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextRouteKey{}, child)))
+		return true
+	}
+	if strings.Trim(rctx.path, "/") != "" || len(gr.routes) == 0 {
+		gr.fail(w, r, http.StatusNotFound, "", nil)
+		return true
+	}
+	return false
+}
+
+// matchSubRouter returns the most specific sub-router matching the path,
+// the rest of the path and the captured parameters. It returns nil when no sub-router matches.
+func (gr *GRouter) matchSubRouter(path string) (*GRouter, string, []param) {
+	var (
+		best      *GRouter
+		bestRest  string
+		bestVals  []param
+		bestScore = -1
+	)
+	for _, sub := range gr.subRouters {
+		rest, params, score, ok := sub.path.match(path)
+		if ok && score > bestScore {
+			best, bestRest, bestVals, bestScore = sub, rest, params, score
+		}
+	}
+	return best, bestRest, bestVals
+}
+
+// Lookup returns the router whose routes serve the path, following the sub-routers as ServeHTTP does.
+// It returns nil when the path is not found.
+func (gr *GRouter) Lookup(path string) *GRouter {
+	if len(gr.subRouters) == 0 {
+		return gr
+	}
+	for {
+		sub, rest, _ := gr.matchSubRouter(path)
+		if sub == nil {
+			break
+		}
+		gr, path = sub, rest
+	}
+	if strings.Trim(path, "/") != "" || len(gr.routes) == 0 {
+		return nil
+	}
+	return gr
+}
+
+type segmentKind int
+
+const (
+	literalSegment segmentKind = iota
+	paramSegment
+	wildcardSegment
+)
+
+type segment struct {
+	kind  segmentKind
+	value string
+}
+
+type pathPattern struct {
+	pattern  string
+	segments []segment
+}
+
+func compilePath(pattern string) pathPattern {
+	p := pathPattern{pattern: "/" + strings.Trim(pattern, "/")}
+	for _, s := range splitPath(pattern) {
+		switch {
+		case s == "*":
+			p.segments = append(p.segments, segment{kind: wildcardSegment, value: "*"})
+		case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}"):
+			p.segments = append(p.segments, segment{kind: paramSegment, value: s[1 : len(s)-1]})
+		default:
+			p.segments = append(p.segments, segment{kind: literalSegment, value: s})
+		}
+	}
+	return p
+}
+
+// match matches the pattern against the beginning of the path.
+// It returns the rest of the path, the captured parameters and a score favoring literal segments.
+func (p pathPattern) match(path string) (string, []param, int, bool) {
+	parts := splitPath(path)
+	var (
+		params []param
+		score  int
+	)
+	for i, s := range p.segments {
+		if s.kind == wildcardSegment {
+			params = append(params, param{name: "*", value: strings.Join(parts[i:], "/")})
+			return "", params, score, true
+		}
+		if i >= len(parts) {
+			return "", nil, 0, false
+		}
+		switch s.kind {
+		case literalSegment:
+			if parts[i] != s.value {
+				return "", nil, 0, false
+			}
+			score += 2
+		case paramSegment:
+			params = append(params, param{name: s.value, value: parts[i]})
+			score++
+		}
+	}
+	return "/" + strings.Join(parts[len(p.segments):], "/"), params, score, true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
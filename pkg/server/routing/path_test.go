@@ -0,0 +1,118 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+var _ = Describe("Path routing", func() {
+	const (
+		bookType   = "application/vnd.athosone.book+json; v=v2"
+		ratingType = "application/vnd.athosone.book.rating+json; v=v2"
+	)
+	var (
+		gRouter          *routing.GRouter
+		responseRecorder *httptest.ResponseRecorder
+		handled          string
+		params           map[string]string
+	)
+	handler := func(name string, keys ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			handled = name
+			for _, k := range keys {
+				params[k] = routing.URLParam(r, k)
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	serve := func(method, path, accept string) {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Accept", accept)
+		gRouter.ServeHTTP(responseRecorder, req)
+	}
+
+	BeforeEach(func() {
+		handled = ""
+		params = map[string]string{}
+		responseRecorder = httptest.NewRecorder()
+		gRouter = routing.NewRouter()
+		gRouter.Route("/books", func(r *routing.GRouter) {
+			r.Get(handler("list")).Produce(bookType)
+			r.Route("/{id}", func(r *routing.GRouter) {
+				r.Get(handler("get", "id")).Produce(bookType)
+				r.Route("/ratings", func(r *routing.GRouter) {
+					r.Get(handler("ratings", "id")).Produce(ratingType)
+				})
+			})
+			r.Route("/latest", func(r *routing.GRouter) {
+				r.Get(handler("latest")).Produce(bookType)
+			})
+		})
+		gRouter.Route("/static/*", func(r *routing.GRouter) {
+			r.Get(handler("static", "*")).Produce("text/*")
+		})
+	})
+
+	It("should dispatch on the path", func() {
+		serve(http.MethodGet, "/books", bookType)
+		Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+		Expect(handled).To(Equal("list"))
+	})
+	It("should ignore trailing slashes", func() {
+		serve(http.MethodGet, "/books/", bookType)
+		Expect(handled).To(Equal("list"))
+	})
+	It("should capture named parameters", func() {
+		serve(http.MethodGet, "/books/42/ratings", ratingType)
+		Expect(handled).To(Equal("ratings"))
+		Expect(params).To(HaveKeyWithValue("id", "42"))
+	})
+	It("should prefer literal segments over parameters", func() {
+		serve(http.MethodGet, "/books/latest", bookType)
+		Expect(handled).To(Equal("latest"))
+	})
+	It("should capture the rest of the path with a wildcard", func() {
+		serve(http.MethodGet, "/static/css/main.css", "text/css")
+		Expect(handled).To(Equal("static"))
+		Expect(params).To(HaveKeyWithValue("*", "css/main.css"))
+	})
+	It("should keep negotiating media types per path", func() {
+		serve(http.MethodGet, "/books/42", ratingType)
+		Expect(responseRecorder.Code).To(Equal(http.StatusNotAcceptable))
+	})
+	It("should return 404 for unknown paths", func() {
+		serve(http.MethodGet, "/authors", bookType)
+		Expect(responseRecorder.Code).To(Equal(http.StatusNotFound))
+		serve(http.MethodGet, "/books/42/reviews", bookType)
+		Expect(responseRecorder.Code).To(Equal(http.StatusNotFound))
+		Expect(handled).To(BeEmpty())
+	})
+	It("should configure the same sub-router for the same pattern", func() {
+		Expect(gRouter.Route("/books/", nil)).To(BeIdenticalTo(gRouter.Route("/books", nil)))
+	})
+	It("should dispatch to mounted routers", func() {
+		authors := routing.NewRouter()
+		authors.Get(handler("authors", "name")).Produce(bookType)
+		gRouter.Mount("/authors/{name}", authors)
+		serve(http.MethodGet, "/authors/tolkien", bookType)
+		Expect(handled).To(Equal("authors"))
+		Expect(params).To(HaveKeyWithValue("name", "tolkien"))
+	})
+	It("should look up the router serving a path", func() {
+		ratings := gRouter.Route("/books", nil).Route("/{id}", nil).Route("/ratings", nil)
+		Expect(gRouter.Lookup("/books/42/ratings")).To(BeIdenticalTo(ratings))
+		Expect(gRouter.Lookup("/books/latest")).To(BeIdenticalTo(gRouter.Route("/books", nil).Route("/latest", nil)))
+		Expect(gRouter.Lookup("/static/css/main.css")).To(BeIdenticalTo(gRouter.Route("/static/*", nil)))
+		Expect(gRouter.Lookup("/authors")).To(BeNil())
+		Expect(gRouter.Lookup("/books/42/reviews")).To(BeNil())
+	})
+	It("should return an empty parameter when it does not exist", func() {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		Expect(routing.URLParam(req, "id")).To(BeEmpty())
+	})
+})
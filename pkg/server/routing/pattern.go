@@ -1,11 +1,17 @@
 package routing
 
-import "strings"
+import (
+	"mime"
+	"strings"
+)
 
 type Pattern struct {
 	prefix   string
 	suffix   string
 	wildcard bool
+	// mediaType is the media type without parameters, it may hold the wildcard.
+	mediaType string
+	params    map[string]string
 }
 
 func NewPattern(value string) Pattern {
@@ -17,16 +23,40 @@ func NewPattern(value string) Pattern {
 	} else {
 		p.prefix = value
 	}
+	p.mediaType = value
+	if mt, params, err := mime.ParseMediaType(value); err == nil {
+		p.mediaType = mt
+		if len(params) > 0 {
+			p.params = params
+		}
+	}
 	return p
 }
 
+// Match is true when the media type matches the pattern, see MatchMediaType.
 func (p Pattern) Match(v string) bool {
-	if !p.wildcard {
-		if p.prefix == v {
-			return true
-		} else {
+	mt, params, err := mime.ParseMediaType(v)
+	if err != nil {
+		return false
+	}
+	return p.MatchMediaType(mt, params)
+}
+
+// MatchMediaType is true when the media type without parameters matches the pattern and carries its parameters.
+// The other parameters of the media type, e.g. charset, do not prevent the match.
+func (p Pattern) MatchMediaType(mediaType string, params map[string]string) bool {
+	if i := strings.IndexByte(p.mediaType, '*'); i >= 0 {
+		prefix, suffix := p.mediaType[:i], p.mediaType[i+1:]
+		if len(mediaType) < len(prefix)+len(suffix) || !strings.HasPrefix(mediaType, prefix) || !strings.HasSuffix(mediaType, suffix) {
+			return false
+		}
+	} else if p.mediaType != mediaType {
+		return false
+	}
+	for k, v := range p.params {
+		if params[k] != v {
 			return false
 		}
 	}
-	return len(v) >= len(p.prefix+p.suffix) && strings.HasPrefix(v, p.prefix) && strings.HasSuffix(v, p.suffix)
+	return true
 }
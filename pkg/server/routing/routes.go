@@ -0,0 +1,109 @@
+package routing
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/athosone/golib/pkg/server/renderer"
+)
+
+// RouteInfo describes a route registered on a GRouter.
+type RouteInfo struct {
+	Method string
+	// Pattern is the path pattern of the route relative to the router Routes was called on.
+	Pattern  string
+	Consumes []string
+	Produces []string
+	Default  bool
+	// Deprecated is true when the whole route is deprecated.
+	Deprecated bool
+	Summary    string
+	// Request is the type of the request body, nil when not registered.
+	Request reflect.Type
+	// Responses are the types of the response bodies by status code.
+	Responses map[int]reflect.Type
+	// Versions are the versions of the bodies, nil when not registered.
+	Versions *renderer.Versions
+}
+
+// Summary describes the route for documentation purpose.
+func (r *Route) Summary(summary string) *Route {
+	r.summary = summary
+	return r
+}
+
+// Request registers the type of the request body, v is a value of that type e.g.: V2Book{}.
+func (r *Route) Request(v any) *Route {
+	r.request = reflect.TypeOf(v)
+	return r
+}
+
+// Response registers the type of the response body for the status code, v is a value of that type e.g.: V2Book{}.
+func (r *Route) Response(status int, v any) *Route {
+	if r.responses == nil {
+		r.responses = map[int]reflect.Type{}
+	}
+	r.responses[status] = reflect.TypeOf(v)
+	return r
+}
+
+// Versions converts the request and response bodies of the route between the versions of the media types,
+// so that the handler only deals with the canonical version, see renderer.Versions.
+func (r *Route) Versions(vs *renderer.Versions) *Route {
+	r.versions = vs
+	return r
+}
+
+// Routes returns the routes of the router and its sub-routers.
+func (gr *GRouter) Routes() []RouteInfo {
+	var routes []RouteInfo
+	_ = gr.Walk(func(ri RouteInfo) error {
+		routes = append(routes, ri)
+		return nil
+	})
+	return routes
+}
+
+// Walk calls fn for every route of the router then of its sub-routers, it stops at the first error.
+func (gr *GRouter) Walk(fn func(RouteInfo) error) error {
+	return gr.walk("/", fn)
+}
+
+func (gr *GRouter) walk(prefix string, fn func(RouteInfo) error) error {
+	for _, route := range gr.routes {
+		if err := fn(route.info(prefix)); err != nil {
+			return err
+		}
+	}
+	for _, sub := range gr.subRouters {
+		if err := sub.walk(joinPath(prefix, sub.path.pattern), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Route) info(pattern string) RouteInfo {
+	ri := RouteInfo{
+		Method:     r.method,
+		Pattern:    pattern,
+		Consumes:   r.consume.fullyQualifiedTypes(),
+		Produces:   r.produce.fullyQualifiedTypes(),
+		Default:    r.isDefault,
+		Deprecated: r.isDeprecated(),
+		Summary:    r.summary,
+		Request:    r.request,
+		Versions:   r.versions,
+	}
+	if len(r.responses) > 0 {
+		ri.Responses = make(map[int]reflect.Type, len(r.responses))
+		for status, t := range r.responses {
+			ri.Responses[status] = t
+		}
+	}
+	return ri
+}
+
+func joinPath(prefix, pattern string) string {
+	return "/" + strings.Trim(strings.TrimRight(prefix, "/")+"/"+strings.Trim(pattern, "/"), "/")
+}
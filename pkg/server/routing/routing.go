@@ -1,14 +1,19 @@
 package routing
 
 import (
-	"container/heap"
 	"fmt"
 	"mime"
 	"net/http"
+	"reflect"
+	"sort"
 	"strings"
+	"sync/atomic"
 
 	"github.com/athosone/golib/pkg/server"
-	"github.com/athosone/golib/pkg/utils"
+	"github.com/athosone/golib/pkg/server/negotiation"
+	"github.com/athosone/golib/pkg/server/renderer"
+	"github.com/athosone/golib/pkg/telemetry"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -16,76 +21,46 @@ const (
 	// HeaderAccept is the header key for the Accept header
 	HeaderAccept      = "Accept"
 	HeaderContentType = "Content-Type"
+	HeaderAllow       = "Allow"
 )
 
-func (gr *GRouter) Post(dest http.HandlerFunc) *Route {
+func (gr *GRouter) handle(method string, dest http.HandlerFunc) *Route {
 	route := &Route{
-		id:     utils.RandomString(30),
+		router: gr,
 		dest:   dest,
-		method: http.MethodPost,
+		method: method,
 	}
 	gr.routes = append(gr.routes, route)
+	gr.invalidate()
 	return route
 }
 
+func (gr *GRouter) Post(dest http.HandlerFunc) *Route {
+	return gr.handle(http.MethodPost, dest)
+}
+
 func (gr *GRouter) Get(dest http.HandlerFunc) *Route {
-	route := &Route{
-		id:     utils.RandomString(30),
-		dest:   dest,
-		method: http.MethodGet,
-	}
-	gr.routes = append(gr.routes, route)
-	return route
+	return gr.handle(http.MethodGet, dest)
 }
 
 func (gr *GRouter) Put(dest http.HandlerFunc) *Route {
-	route := &Route{
-		id:     utils.RandomString(30),
-		dest:   dest,
-		method: http.MethodPut,
-	}
-	gr.routes = append(gr.routes, route)
-	return route
+	return gr.handle(http.MethodPut, dest)
 }
 
 func (gr *GRouter) Delete(dest http.HandlerFunc) *Route {
-	route := &Route{
-		id:     utils.RandomString(30),
-		dest:   dest,
-		method: http.MethodDelete,
-	}
-	gr.routes = append(gr.routes, route)
-	return route
+	return gr.handle(http.MethodDelete, dest)
 }
 
 func (gr *GRouter) Patch(dest http.HandlerFunc) *Route {
-	route := &Route{
-		id:     utils.RandomString(30),
-		dest:   dest,
-		method: http.MethodPatch,
-	}
-	gr.routes = append(gr.routes, route)
-	return route
+	return gr.handle(http.MethodPatch, dest)
 }
 
 func (gr *GRouter) Head(dest http.HandlerFunc) *Route {
-	route := &Route{
-		id:     utils.RandomString(30),
-		dest:   dest,
-		method: http.MethodHead,
-	}
-	gr.routes = append(gr.routes, route)
-	return route
+	return gr.handle(http.MethodHead, dest)
 }
 
 func (gr *GRouter) Options(dest http.HandlerFunc) *Route {
-	route := &Route{
-		id:     utils.RandomString(30),
-		dest:   dest,
-		method: http.MethodOptions,
-	}
-	gr.routes = append(gr.routes, route)
-	return route
+	return gr.handle(http.MethodOptions, dest)
 }
 
 func (r *Route) Consume(mediaTypes ...string) *Route {
@@ -93,6 +68,7 @@ func (r *Route) Consume(mediaTypes ...string) *Route {
 		formattedMediaType := formatMediaType(mediaType)
 		r.consume = append(r.consume, NewPattern(formattedMediaType))
 	}
+	r.router.invalidate()
 	return r
 }
 
@@ -103,6 +79,7 @@ func (r *Route) Produce(mediaTypes ...string) *Route {
 		formattedMediaType := formatMediaType(mediaType)
 		r.produce = append(r.produce, NewPattern(formattedMediaType))
 	}
+	r.router.invalidate()
 	return r
 }
 
@@ -123,91 +100,205 @@ func formatMediaType(mediaType string) string {
 type Patterns []Pattern
 
 type Route struct {
-	id        string
-	method    string
-	isDefault bool
-	dest      http.HandlerFunc
-	consume   Patterns
-	produce   Patterns
+	router     *GRouter
+	method     string
+	isDefault  bool
+	dest       http.HandlerFunc
+	consume    Patterns
+	produce    Patterns
+	summary    string
+	request    reflect.Type
+	responses  map[int]reflect.Type
+	lifecycles []*lifecycle
+	versions   *renderer.Versions
 }
 
 type GRouter struct {
-	routes []*Route
+	routes              []*Route
+	subRouters          []*GRouter
+	path                pathPattern
+	renderer            *renderer.Renderer
+	problemResponses    bool
+	deprecationObserver DeprecationObserver
+	telemetry           *telemetry.Telemetry
+	// table holds the *routeTable compiled from routes, nil when it must be rebuilt
+	table atomic.Value
 }
 
-func NewRouter() *GRouter {
-	return &GRouter{
+func NewRouter(opts ...RouterOption) *GRouter {
+	gr := &GRouter{
 		routes: []*Route{},
 	}
+	for _, opt := range opts {
+		opt(gr)
+	}
+	return gr
 }
 
 func (gr *GRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	accept := r.Header.Get(HeaderAccept)
+	if gr.telemetry != nil && r.Context().Value(contextRouteKey{}) == nil {
+		// the outermost router traces the dispatch, the sub-routers annotate its span
+		var span trace.Span
+		r, span = gr.startSpan(r)
+		defer span.End()
+	}
+	gr.dispatch(w, r)
+}
+
+func (gr *GRouter) dispatch(w http.ResponseWriter, r *http.Request) {
+	if gr.renderer != nil {
+		r = r.WithContext(renderer.NewContext(r.Context(), gr.renderer))
+	}
+	if gr.routePath(w, r) {
+		return
+	}
+	if r.Method == http.MethodOptions && !gr.hasMethod(http.MethodOptions) {
+		gr.options(w, r)
+		return
+	}
+	method := gr.routeMethod(r.Method)
+	if method != r.Method {
+		// HEAD requests are served by GET routes without body
+		w = &headResponseWriter{w}
+	}
 	contentType := r.Header.Get(HeaderContentType)
 
-	acceptHeap, err := server.ParseMediaType(accept)
+	acceptTypes, err := parseMediaTypes(r.Header.Get(HeaderAccept))
 	if err != nil {
 		// if invalid accept header, return 406
-		w.WriteHeader(http.StatusNotAcceptable)
+		gr.notAcceptable(w, r)
 		return
 	}
 
-	consumeHeap, err := server.ParseMediaType(contentType)
+	consumeTypes, err := parseMediaTypes(contentType)
 	if err != nil {
 		// if invalid content-type header, return 406
-		w.WriteHeader(http.StatusNotAcceptable)
+		gr.notAcceptable(w, r)
 		return
 	}
-	routes := []*Route{}
-	for _, route := range gr.routes {
-		if route.isMethodMatch(r.Method) {
-			routes = append(routes, route)
-		}
+	mt := gr.compiled().methods[strings.ToUpper(method)]
+	if mt == nil {
+		allowed := gr.allowedMethods()
+		w.Header().Set(HeaderAllow, strings.Join(allowed, ", "))
+		gr.fail(w, r, http.StatusMethodNotAllowed, "allowed", allowed)
+		return
 	}
 
-	if len(routes) == 0 {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	acceptAny := len(acceptTypes) == 0 || (acceptTypes[0].IsAny && acceptTypes[0].Quality > 0)
+	consumed := false
+	for _, consumeContentType := range consumeTypes {
+		i := mt.first(&mt.consume, consumeContentType, nil)
+		if i < 0 {
+			continue
+		}
+		if acceptAny {
+			route := mt.routes[i]
+			if len(route.produce) > 0 {
+				setHeader(r.Header, HeaderAccept, route.produce[0].getFullyQualifiedType())
+			}
+			gr.serve(route, w, r)
+			return
+		}
+		consumed = true
+	}
+	if contentType != "" && !consumed {
+		gr.negotiate(w, r)
 		return
 	}
 
-	mRouter := map[string]*Route{}
-	for len(consumeHeap) > 0 {
-		consumeContentType := heap.Pop(&consumeHeap).(server.ContentMediaType)
-		for _, route := range routes {
+	// with a Content-Type, only the routes consuming it can be selected
+	// and wildcards cannot select a media type excluded with q=0
+	var acceptContentType server.ContentMediaType
+	hasExclusions := len(acceptTypes) > 0 && acceptTypes[len(acceptTypes)-1].Quality == 0
+	keep := func(route *Route) bool {
+		if hasExclusions && acceptContentType.IsAny && len(route.produce) > 0 && acceptTypes.Quality(route.produce[0].getFullyQualifiedType()) == 0 {
+			return false
+		}
+		if contentType == "" {
+			return true
+		}
+		for _, consumeContentType := range consumeTypes {
 			if route.IsConsumeMatch(r, consumeContentType) {
-				if len(acceptHeap) == 0 || acceptHeap[0].IsAny {
-					if len(route.produce) > 0 {
-						r.Header.Set(HeaderAccept, route.produce[0].getFullyQualifiedType())
-					}
-					route.ServeHTTP(w, r)
-					return
-				}
-				mRouter[route.id] = route
+				return true
 			}
 		}
+		return false
 	}
-	if contentType != "" && len(mRouter) == 0 {
-		gr.negotiate(w, r)
+	for _, acceptContentType = range acceptTypes {
+		if acceptContentType.Quality == 0 {
+			// excluded media types are ordered last
+			break
+		}
+		if i := mt.first(&mt.produce, acceptContentType, keep); i >= 0 {
+			route := mt.routes[i]
+			setHeader(r.Header, HeaderAccept, acceptContentType.FullyQualifiedType)
+			if acceptContentType.IsAny && len(route.produce) > 0 {
+				setHeader(r.Header, HeaderAccept, route.produce[0].getFullyQualifiedType())
+			}
+			gr.serve(route, w, r)
+			return
+		}
+	}
+	gr.negotiate(w, r)
+}
+
+// compiled returns the route table, compiling it when the routes changed.
+func (gr *GRouter) compiled() *routeTable {
+	if t, _ := gr.table.Load().(*routeTable); t != nil {
+		return t
+	}
+	t := compileTable(gr.routes)
+	gr.table.Store(t)
+	return t
+}
+
+// invalidate discards the route table after a change of the routes.
+func (gr *GRouter) invalidate() {
+	if gr != nil {
+		gr.table.Store((*routeTable)(nil))
+	}
+}
+
+// serve dispatches the request to the route selected by the negotiation.
+func (gr *GRouter) serve(route *Route, w http.ResponseWriter, r *http.Request) {
+	if !gr.serveDeprecated(route, w, r) {
 		return
 	}
+	if len(route.produce) > 0 {
+		negotiation.AddVary(w.Header(), HeaderAccept)
+	}
+	if route.versions != nil {
+		r = r.WithContext(renderer.NewVersionsContext(r.Context(), route.versions))
+	}
+	if gr.telemetry != nil {
+		annotateRoute(r, route)
+	}
+	if info := server.RouteInfoFromContext(r.Context()); info != nil {
+		info.Pattern = "/"
+		if rctx, ok := r.Context().Value(contextRouteKey{}).(*routeContext); ok && rctx.pattern != "" {
+			info.Pattern = rctx.pattern
+		}
+		info.MediaType = route.producedMediaType(r.Header.Get(HeaderAccept))
+	}
+	route.ServeHTTP(w, r)
+}
 
-	for len(acceptHeap) > 0 {
-		acceptContentType := heap.Pop(&acceptHeap).(server.ContentMediaType)
-		for _, route := range routes {
-			if route.IsProduceMatch(r, acceptContentType) {
-				_, hasRoutePair := mRouter[route.id]
-				if contentType == "" || hasRoutePair {
-					r.Header.Set(HeaderAccept, acceptContentType.FullyQualifiedType)
-					if acceptContentType.IsAny && len(route.produce) > 0 {
-						r.Header.Set(HeaderAccept, route.produce[0].getFullyQualifiedType())
-					}
-					route.ServeHTTP(w, r)
-					return
-				}
+// producedMediaType returns the media type of the route matching the negotiated one, so that clients cannot
+// report arbitrary values: the pattern registered with Produce, or the negotiated media type when its version
+// is registered in the versions of the route and the pattern holds none.
+func (route *Route) producedMediaType(negotiated string) string {
+	for _, p := range route.produce {
+		if !p.Match(negotiated) {
+			continue
+		}
+		if vs := route.versions; vs != nil && p.params[vs.Param] == "" {
+			if _, ok := vs.TypeOf(vs.MediaTypeVersion(negotiated)); ok {
+				return negotiated
 			}
 		}
+		return p.getFullyQualifiedType()
 	}
-	gr.negotiate(w, r)
+	return ""
 }
 
 func (r *Route) IsProduceMatch(req *http.Request, value server.ContentMediaType) bool {
@@ -220,6 +311,7 @@ func (r *Route) IsConsumeMatch(req *http.Request, value server.ContentMediaType)
 
 func (r *Route) SetDefault() {
 	r.isDefault = true
+	r.router.invalidate()
 }
 
 func (ro *Route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -228,15 +320,20 @@ func (ro *Route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (r *Route) isMatch(req *http.Request, value server.ContentMediaType, patterns Patterns) bool {
 	if r.isDefault && value.IsAny {
-		return r.isMethodMatch(req.Method)
+		return true
+	}
+	if value.IsAny {
+		return false
 	}
-	return !value.IsAny && match(value.FullyQualifiedType, patterns)
+	for _, pattern := range patterns {
+		if pattern.MatchMediaType(value.Type, value.Params) {
+			return true
+		}
+	}
+	return false
 }
 
 func match(value string, patterns []Pattern) bool {
-	if len(patterns) == 0 {
-		return false
-	}
 	for _, pattern := range patterns {
 		if pattern.Match(value) {
 			return true
@@ -253,41 +350,155 @@ func (r *Route) isMethodMatch(method string) bool {
 
 func (gr *GRouter) negotiate(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost && req.Method != http.MethodPatch {
-		w.WriteHeader(http.StatusNotAcceptable)
+		gr.notAcceptable(w, req)
 		return
 	}
-	var b strings.Builder
+	supportedMediaTypes := gr.acceptedMediaTypes(req.Method)
+	w.Header().Set(fmt.Sprintf("Accept-%s", req.Method), strings.Join(supportedMediaTypes, ", "))
+	gr.fail(w, req, http.StatusUnsupportedMediaType, "acceptable", supportedMediaTypes)
+}
+
+// AcceptedMediaTypes returns the media types of the request bodies accepted by the routes of the method,
+// as advertised in the Accept-Post and Accept-Patch headers.
+func (gr *GRouter) AcceptedMediaTypes(method string) []string {
+	return gr.acceptedMediaTypes(method)
+}
+
+// acceptedMediaTypes returns the media types consumed by the routes of the method,
+// the produced ones are used for routes without Consume.
+func (gr *GRouter) acceptedMediaTypes(method string) []string {
+	var supportedMediaTypes []string
 	for _, route := range gr.routes {
-		if route.isMethodMatch(req.Method) {
-			var supportedMediaTypes string
+		if route.isMethodMatch(method) {
 			if len(route.consume) > 0 {
-				supportedMediaTypes = route.getSupportedConsumeTypes()
+				supportedMediaTypes = append(supportedMediaTypes, route.consume.fullyQualifiedTypes()...)
 			} else {
-				supportedMediaTypes = route.getSupportedProduceTypes()
-			}
-			if b.Len() > 0 {
-				b.WriteString(", ")
+				supportedMediaTypes = append(supportedMediaTypes, route.produce.fullyQualifiedTypes()...)
 			}
-			b.WriteString(supportedMediaTypes)
 		}
 	}
-	w.Header().Set(fmt.Sprintf("Accept-%s", req.Method), b.String())
-	w.WriteHeader(http.StatusUnsupportedMediaType)
+	return supportedMediaTypes
+}
+
+func (gr *GRouter) notAcceptable(w http.ResponseWriter, req *http.Request) {
+	var producedMediaTypes []string
+	method := gr.routeMethod(req.Method)
+	for _, route := range gr.routes {
+		if route.isMethodMatch(method) {
+			producedMediaTypes = append(producedMediaTypes, route.produce.fullyQualifiedTypes()...)
+		}
+	}
+	gr.fail(w, req, http.StatusNotAcceptable, "acceptable", producedMediaTypes)
+}
+
+// options answers OPTIONS requests with the allowed methods and the media types accepted by POST and PATCH routes.
+func (gr *GRouter) options(w http.ResponseWriter, req *http.Request) {
+	allowed := gr.allowedMethods()
+	w.Header().Set(HeaderAllow, strings.Join(allowed, ", "))
+	for _, method := range []string{http.MethodPost, http.MethodPatch} {
+		if gr.hasMethod(method) {
+			w.Header().Set(fmt.Sprintf("Accept-%s", method), strings.Join(gr.acceptedMediaTypes(method), ", "))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fail writes the status, or a problem document holding the values
+// when the router has been created with WithProblemResponses.
+func (gr *GRouter) fail(w http.ResponseWriter, req *http.Request, status int, key string, values []string) {
+	if gr.telemetry != nil {
+		annotateFailure(req, status)
+	}
+	if !gr.problemResponses {
+		w.WriteHeader(status)
+		return
+	}
+	problem := renderer.NewProblem(status, "")
+	if key != "" {
+		problem.With(key, values)
+	}
+	problem.Instance = req.URL.Path
+	_ = renderer.Problem(w, req, problem)
 }
 
-func (r *Route) getSupportedConsumeTypes() string {
-	return r.consume.concatenate()
+// AllowedMethods returns the methods answered by the router:
+// the methods of the registered routes, HEAD when GET is registered and OPTIONS.
+func (gr *GRouter) AllowedMethods() []string {
+	return gr.allowedMethods()
 }
-func (r *Route) getSupportedProduceTypes() string {
-	return r.produce.concatenate()
+
+func (gr *GRouter) allowedMethods() []string {
+	set := map[string]struct{}{http.MethodOptions: {}}
+	for _, route := range gr.routes {
+		set[route.method] = struct{}{}
+		if route.method == http.MethodGet {
+			set[http.MethodHead] = struct{}{}
+		}
+	}
+	methods := make([]string, 0, len(set))
+	for m := range set {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		oi, oj := methodOrder(methods[i]), methodOrder(methods[j])
+		if oi == oj {
+			return methods[i] < methods[j]
+		}
+		return oi < oj
+	})
+	return methods
+}
+
+func methodOrder(method string) int {
+	for i, m := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions} {
+		if m == method {
+			return i
+		}
+	}
+	return 100
+}
+
+func (gr *GRouter) hasMethod(method string) bool {
+	_, ok := gr.compiled().methods[strings.ToUpper(method)]
+	return ok
+}
+
+// routeMethod returns the method of the routes serving the request method,
+// HEAD is served by GET routes unless HEAD routes are registered.
+func (gr *GRouter) routeMethod(method string) string {
+	if method == http.MethodHead && !gr.hasMethod(http.MethodHead) {
+		return http.MethodGet
+	}
+	return method
+}
+
+// headResponseWriter discards the body written by GET routes serving HEAD requests.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Flush sends the headers when the underlying writer supports it, there is no body to flush.
+func (w *headResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, see http.ResponseController.
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
-func (p Patterns) concatenate() string {
+func (p Patterns) fullyQualifiedTypes() []string {
 	var types []string
 	for _, pattern := range p {
 		types = append(types, pattern.getFullyQualifiedType())
 	}
-	return strings.Join(types, ", ")
+	return types
 }
 
 func (p Pattern) getFullyQualifiedType() string {
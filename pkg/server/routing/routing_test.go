@@ -8,6 +8,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/athosone/golib/pkg/server/renderer"
 	"github.com/athosone/golib/pkg/server/routing"
 )
 
@@ -83,6 +84,55 @@ var _ = Describe("Routing", func() {
 				Expect(responseRecorder.Code).To(Equal(http.StatusOK))
 			})
 		})
+		When("media types carry additional parameters", func() {
+			var served string
+			BeforeEach(func() {
+				served = ""
+				handler := func(name string) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						served = name
+						w.WriteHeader(http.StatusOK)
+					}
+				}
+				gRouter.Post(handler("json")).Consume("application/json").Produce("application/json")
+				gRouter.Post(handler("v1")).Consume(v1Type)
+				gRouter.Post(handler("v2")).Consume(v2Type)
+				gRouter.Get(handler("json")).Produce("application/json")
+			})
+			It("should ignore the charset of the content type", func() {
+				for _, contentType := range []string{"application/json; charset=utf-8", "application/json;charset=UTF-8"} {
+					req, _ = http.NewRequest(http.MethodPost, "/", nil)
+					req.Header.Set("Content-Type", contentType)
+					responseRecorder = httptest.NewRecorder()
+					gRouter.ServeHTTP(responseRecorder, req)
+					Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+					Expect(served).To(Equal("json"))
+				}
+			})
+			It("should ignore the charset of the accepted media type", func() {
+				req, _ = http.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept", "application/json; charset=utf-8")
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+				Expect(served).To(Equal("json"))
+			})
+			It("should match the parameters of the route", func() {
+				req, _ = http.NewRequest(http.MethodPost, "/", nil)
+				req.Header.Set("Content-Type", v2Type+"; charset=utf-8")
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+				Expect(served).To(Equal("v2"))
+			})
+			It("should prefer the route matching the most parameters", func() {
+				gRouter = routing.NewRouter()
+				gRouter.Post(func(w http.ResponseWriter, r *http.Request) { served = "any" }).Consume("application/vnd.athosone.innersource+json")
+				gRouter.Post(func(w http.ResponseWriter, r *http.Request) { served = "v2" }).Consume(v2Type)
+				req, _ = http.NewRequest(http.MethodPost, "/", nil)
+				req.Header.Set("Content-Type", v2Type)
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(served).To(Equal("v2"))
+			})
+		})
 		When("Defining a default route", func() {
 			BeforeEach(func() {
 				gRouter.Get(func(w http.ResponseWriter, r *http.Request) {
@@ -102,6 +152,19 @@ var _ = Describe("Routing", func() {
 				Expect(responseRecorder.Code).To(Equal(http.StatusOK))
 				Expect(req.Header.Get("Accept")).To(Equal(v1Type))
 			})
+			It("should not select an excluded media type with a wildcard", func() {
+				req, _ = http.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept", "*/*, "+v1Type+";q=0")
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusNotAcceptable))
+			})
+			It("should prefer the most specific range", func() {
+				req, _ = http.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept", "*/*, "+v2Type)
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+				Expect(req.Header.Get("Accept")).To(Equal(v2Type))
+			})
 		})
 		When("sending wildcard content", func() {
 			BeforeEach(func() {
@@ -229,6 +292,78 @@ var _ = Describe("Routing", func() {
 				Expect(responseRecorder.Code).To(Equal(http.StatusNotAcceptable))
 			})
 		})
+		When("routes change after the first request", func() {
+			It("should serve the new media types", func() {
+				route := gRouter.Get(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}).Produce(v1Type)
+				req, _ = http.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept", v2Type)
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusNotAcceptable))
+
+				route.Produce(v2Type)
+				responseRecorder = httptest.NewRecorder()
+				req.Header.Set("Accept", v2Type)
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusOK))
+			})
+		})
+		When("the router carries its own renderer", func() {
+			BeforeEach(func() {
+				rd := renderer.New()
+				rd.Unregister("xml")
+				gRouter = routing.NewRouter(routing.WithRenderer(rd))
+				gRouter.Get(func(w http.ResponseWriter, r *http.Request) {
+					Expect(renderer.OK(w, r, "test")).NotTo(Succeed())
+				}).Produce("application/xml")
+			})
+			It("should inject it in the request context", func() {
+				req, _ = http.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept", "application/xml")
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+		When("the router renders problems", func() {
+			BeforeEach(func() {
+				gRouter = routing.NewRouter(routing.WithProblemResponses())
+				gRouter.Get(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}).Produce(v1Type, v2Type)
+				gRouter.Post(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusCreated)
+				}).Consume(v1Type)
+			})
+			It("should list the produced media types on 406", func() {
+				req, _ = http.NewRequest(http.MethodGet, "/books", nil)
+				req.Header.Set("Accept", unsupportedType)
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusNotAcceptable))
+				Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("application/problem+xml"))
+				Expect(responseRecorder.Body.String()).To(ContainSubstring("<acceptable><i>" + v1Type + "</i><i>" + v2Type + "</i></acceptable>"))
+			})
+			It("should list the consumed media types on 415", func() {
+				req, _ = http.NewRequest(http.MethodPost, "/books", nil)
+				req.Header.Set("Content-Type", unsupportedType)
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusUnsupportedMediaType))
+				Expect(responseRecorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+				Expect(responseRecorder.Body.String()).To(MatchJSON(fmt.Sprintf(`{
+					"type": "about:blank",
+					"title": "Unsupported Media Type",
+					"status": 415,
+					"instance": "/books",
+					"acceptable": [%q]
+				}`, v1Type)))
+			})
+			It("should list the allowed methods on 405", func() {
+				req, _ = http.NewRequest(http.MethodTrace, "/books", nil)
+				gRouter.ServeHTTP(responseRecorder, req)
+				Expect(responseRecorder.Code).To(Equal(http.StatusMethodNotAllowed))
+				Expect(responseRecorder.Body.String()).To(ContainSubstring(`"allowed":["GET","HEAD","POST","OPTIONS"]`))
+			})
+		})
 		When("requesting with bad http verb", func() {
 			It("should return 405", func() {
 				req, _ = http.NewRequest(http.MethodTrace, "/", nil)
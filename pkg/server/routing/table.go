@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/athosone/golib/pkg/server"
+)
+
+// routeTable is the lookup structure compiled from the routes of a GRouter.
+// It is rebuilt on the first request following a change of the routes.
+type routeTable struct {
+	methods map[string]*methodTable
+}
+
+// methodTable indexes the routes of a method by the media types they consume and produce.
+type methodTable struct {
+	routes  []*Route
+	consume mediaTypeIndex
+	produce mediaTypeIndex
+	// defaults are the default routes, matching wildcard media types.
+	defaults []int
+}
+
+// mediaTypeIndex finds the routes matching a media type.
+// Patterns without wildcard are indexed by their media type without parameters.
+type mediaTypeIndex struct {
+	exact     map[string][]indexedPattern
+	wildcards []indexedPattern
+}
+
+type indexedPattern struct {
+	pattern Pattern
+	route   int
+}
+
+func compileTable(routes []*Route) *routeTable {
+	t := &routeTable{methods: map[string]*methodTable{}}
+	for _, route := range routes {
+		method := strings.ToUpper(route.method)
+		mt, ok := t.methods[method]
+		if !ok {
+			mt = &methodTable{
+				consume: mediaTypeIndex{exact: map[string][]indexedPattern{}},
+				produce: mediaTypeIndex{exact: map[string][]indexedPattern{}},
+			}
+			t.methods[method] = mt
+		}
+		i := len(mt.routes)
+		mt.routes = append(mt.routes, route)
+		mt.consume.add(i, route.consume)
+		mt.produce.add(i, route.produce)
+		if route.isDefault {
+			mt.defaults = append(mt.defaults, i)
+		}
+	}
+	return t
+}
+
+func (ix *mediaTypeIndex) add(route int, patterns Patterns) {
+	for _, p := range patterns {
+		if p.wildcard {
+			ix.wildcards = append(ix.wildcards, indexedPattern{pattern: p, route: route})
+			continue
+		}
+		ix.exact[p.mediaType] = append(ix.exact[p.mediaType], indexedPattern{pattern: p, route: route})
+	}
+}
+
+// first returns the index of the route matching the media type and accepted by keep, -1 when none.
+// The routes matching the most parameters of the media type are preferred, then the first registered one.
+// Wildcard media types only match default routes.
+func (mt *methodTable) first(ix *mediaTypeIndex, value server.ContentMediaType, keep func(*Route) bool) int {
+	if value.IsAny {
+		for _, i := range mt.defaults {
+			if keep == nil || keep(mt.routes[i]) {
+				return i
+			}
+		}
+		return -1
+	}
+	best, bestParams := -1, -1
+	consider := func(candidates []indexedPattern) {
+		for _, c := range candidates {
+			n := len(c.pattern.params)
+			if n < bestParams || (n == bestParams && c.route >= best) {
+				continue
+			}
+			if !c.pattern.MatchMediaType(value.Type, value.Params) {
+				continue
+			}
+			if keep == nil || keep(mt.routes[c.route]) {
+				best, bestParams = c.route, n
+			}
+		}
+	}
+	consider(ix.exact[value.Type])
+	consider(ix.wildcards)
+	return best
+}
+
+// mediaTypeCacheSize bounds the number of header values kept by parseMediaTypes.
+const mediaTypeCacheSize = 1024
+
+// maxCachedMediaTypesLength is the length above which header values are parsed without being cached,
+// so that clients cannot pin large values in memory.
+const maxCachedMediaTypesLength = 256
+
+type parsedMediaTypes struct {
+	values server.AcceptList
+	err    error
+}
+
+var mediaTypeCache = struct {
+	sync.RWMutex
+	entries map[string]parsedMediaTypes
+}{entries: map[string]parsedMediaTypes{}}
+
+// parseMediaTypes returns the media types of the header value ordered by preference, see server.ParseAccept.
+// Results of short values are cached since clients tend to send the same headers, the returned list must not be modified.
+func parseMediaTypes(value string) (server.AcceptList, error) {
+	if value == "" {
+		return nil, nil
+	}
+	mediaTypeCache.RLock()
+	parsed, ok := mediaTypeCache.entries[value]
+	mediaTypeCache.RUnlock()
+	if ok {
+		return parsed.values, parsed.err
+	}
+
+	parsed.values, parsed.err = server.ParseAccept(value)
+	if len(value) > maxCachedMediaTypesLength {
+		return parsed.values, parsed.err
+	}
+	mediaTypeCache.Lock()
+	if len(mediaTypeCache.entries) >= mediaTypeCacheSize {
+		mediaTypeCache.entries = map[string]parsedMediaTypes{}
+	}
+	mediaTypeCache.entries[value] = parsed
+	mediaTypeCache.Unlock()
+	return parsed.values, parsed.err
+}
+
+// setHeader sets the header value, reusing the existing slice.
+func setHeader(h http.Header, key, value string) {
+	if values := h[key]; len(values) == 1 {
+		values[0] = value
+		return
+	}
+	h[key] = []string{value}
+}
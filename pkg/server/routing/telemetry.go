@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	attributeMediaType   = attribute.Key("golib.negotiation.media_type")
+	attributeContentType = attribute.Key("golib.negotiation.content_type")
+	attributeProduces    = attribute.Key("golib.route.produces")
+	attributeConsumes    = attribute.Key("golib.route.consumes")
+	attributeDefault     = attribute.Key("golib.route.default")
+)
+
+// startSpan starts the span of the dispatch, continuing the trace propagated in the headers
+// when the request is not already traced (e.g. by otelhttp).
+func (gr *GRouter) startSpan(r *http.Request) (*http.Request, trace.Span) {
+	ctx := r.Context()
+	kind := trace.SpanKindInternal
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+		kind = trace.SpanKindServer
+	}
+	ctx, span := gr.telemetry.Tracer.Start(ctx, "GRouter "+r.Method,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+		),
+	)
+	return r.WithContext(ctx), span
+}
+
+// annotateRoute names the span after the route chosen for the request and records the negotiated media types.
+func annotateRoute(r *http.Request, route *Route) {
+	span := trace.SpanFromContext(r.Context())
+	if !span.IsRecording() {
+		return
+	}
+	if rctx, ok := r.Context().Value(contextRouteKey{}).(*routeContext); ok && rctx.pattern != "" {
+		span.SetName(r.Method + " " + rctx.pattern)
+		span.SetAttributes(semconv.HTTPRouteKey.String(rctx.pattern))
+	}
+	attrs := []attribute.KeyValue{
+		attributeProduces.StringSlice(route.produce.fullyQualifiedTypes()),
+		attributeConsumes.StringSlice(route.consume.fullyQualifiedTypes()),
+		attributeDefault.Bool(route.isDefault),
+	}
+	if len(route.produce) > 0 {
+		attrs = append(attrs, attributeMediaType.String(r.Header.Get(HeaderAccept)))
+	}
+	if contentType := r.Header.Get(HeaderContentType); contentType != "" {
+		attrs = append(attrs, attributeContentType.String(contentType))
+	}
+	span.SetAttributes(attrs...)
+}
+
+// annotateFailure records the status of the requests the router could not dispatch.
+func annotateFailure(r *http.Request, status int) {
+	span := trace.SpanFromContext(r.Context())
+	if !span.IsRecording() {
+		return
+	}
+	// client errors leave the status of the span unset
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+}
@@ -0,0 +1,86 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/athosone/golib/pkg/server/routing"
+	"github.com/athosone/golib/pkg/telemetry"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+var _ = Describe("Telemetry", func() {
+	var (
+		recorder *tracetest.SpanRecorder
+		router   *routing.GRouter
+	)
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		router = routing.NewRouter(routing.WithTelemetry(telemetry.WithTracerProvider(tp)))
+		router.Route("/api", func(api *routing.GRouter) {
+			api.Route("/books/{id}", func(books *routing.GRouter) {
+				books.Get(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}).Produce("application/vnd.athosone.book+json; v=v1", "application/vnd.athosone.book+json; v=v2").SetDefault()
+			})
+		})
+	})
+
+	It("should trace the dispatch with the chosen route and the negotiated media type", func() {
+		req := httptest.NewRequest(http.MethodGet, "/api/books/42", nil)
+		req.Header.Set("Accept", "application/vnd.athosone.book+json; v=v2")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		span := spans[0]
+		Expect(span.Name()).To(Equal("GET /api/books/{id}"))
+		Expect(span.SpanKind()).To(Equal(trace.SpanKindServer))
+		attrs := spanAttributes(span)
+		Expect(attrs).To(HaveKeyWithValue(attribute.Key("http.route"), attribute.StringValue("/api/books/{id}")))
+		Expect(attrs).To(HaveKeyWithValue(attribute.Key("golib.negotiation.media_type"),
+			attribute.StringValue("application/vnd.athosone.book+json; v=v2")))
+		Expect(attrs[attribute.Key("golib.route.produces")].AsStringSlice()).To(HaveLen(2))
+		Expect(attrs).To(HaveKeyWithValue(attribute.Key("golib.route.default"), attribute.BoolValue(true)))
+	})
+
+	It("should record the status of the requests that cannot be dispatched", func() {
+		req := httptest.NewRequest(http.MethodGet, "/api/books/42", nil)
+		req.Header.Set("Accept", "application/xml")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("GRouter GET"))
+		Expect(spanAttributes(spans[0])).To(HaveKeyWithValue(attribute.Key("http.status_code"), attribute.IntValue(http.StatusNotAcceptable)))
+	})
+
+	It("should continue the trace of the context", func() {
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ctx, parent := tp.Tracer("test").Start(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "parent")
+		req := httptest.NewRequest(http.MethodGet, "/api/books/42", nil).WithContext(ctx)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		parent.End()
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].SpanKind()).To(Equal(trace.SpanKindInternal))
+		Expect(spans[0].Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+	})
+})
@@ -0,0 +1,84 @@
+package routing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/athosone/golib/pkg/server"
+	"github.com/athosone/golib/pkg/server/renderer"
+	"github.com/athosone/golib/pkg/server/routing"
+)
+
+type bookV1 struct {
+	Title string `json:"title"`
+}
+
+type bookV2 struct {
+	Name string `json:"name"`
+}
+
+var _ = Describe("Versions", func() {
+	const (
+		v1Type = "application/vnd.athosone.book+json; v=v1"
+		v2Type = "application/vnd.athosone.book+json; v=v2"
+	)
+	var (
+		gRouter          *routing.GRouter
+		responseRecorder *httptest.ResponseRecorder
+		versions         *renderer.Versions
+	)
+	BeforeEach(func() {
+		responseRecorder = httptest.NewRecorder()
+		versions = renderer.NewVersions("v2")
+		renderer.Convert(versions, "v2", "v1", func(b bookV2) (bookV1, error) {
+			return bookV1{Title: b.Name}, nil
+		})
+		renderer.Convert(versions, "v1", "v2", func(b bookV1) (bookV2, error) {
+			return bookV2{Name: b.Title}, nil
+		})
+		gRouter = routing.NewRouter()
+		gRouter.Post(func(w http.ResponseWriter, r *http.Request) {
+			var book bookV2
+			if renderer.Bind(w, r, &book) == nil {
+				_ = renderer.Created(w, r, book)
+			}
+		}).Consume(v1Type, v2Type).Produce(v2Type, v1Type).Versions(versions)
+	})
+
+	It("should serve the requested version from the canonical handler", func() {
+		req, _ := http.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"title":"Dune"}`))
+		req.Header.Set("Content-Type", v1Type)
+		req.Header.Set("Accept", v1Type)
+		gRouter.ServeHTTP(responseRecorder, req)
+		Expect(responseRecorder.Code).To(Equal(http.StatusCreated))
+		Expect(responseRecorder.Header().Get("Content-Type")).To(Equal(v1Type))
+		Expect(responseRecorder.Body.String()).To(MatchJSON(`{"title":"Dune"}`))
+	})
+	It("should upgrade the request body to the canonical version", func() {
+		req, _ := http.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"title":"Dune"}`))
+		req.Header.Set("Content-Type", v1Type)
+		req.Header.Set("Accept", v2Type)
+		gRouter.ServeHTTP(responseRecorder, req)
+		Expect(responseRecorder.Code).To(Equal(http.StatusCreated))
+		Expect(responseRecorder.Body.String()).To(MatchJSON(`{"name":"Dune"}`))
+	})
+	It("should report the registered versions in the route info", func() {
+		gRouter.Get(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}).Produce("application/vnd.athosone.book+json").Versions(versions)
+		mediaTypeOf := func(accept string) string {
+			ctx, info := server.NewRouteInfoContext(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/books", nil)
+			req.Header.Set("Accept", accept)
+			gRouter.ServeHTTP(httptest.NewRecorder(), req)
+			return info.MediaType
+		}
+		Expect(mediaTypeOf(v1Type)).To(Equal(v1Type))
+		Expect(mediaTypeOf("application/vnd.athosone.book+json; v=v9")).To(Equal("application/vnd.athosone.book+json"))
+	})
+})
@@ -0,0 +1,111 @@
+// Package telemetry holds the OpenTelemetry tracer and meter used by the instrumentation of golib.
+// Instrumentation is optional: it is enabled per component (routing.WithTelemetry, middleware.RequestMetrics,
+// auth.WithTelemetry and pubsub.WithTelemetry) and uses the global providers unless others are given.
+package telemetry
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer and the meter of golib.
+const InstrumentationName = "github.com/athosone/golib"
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures the providers of the instrumentation.
+type Option func(*config)
+
+// WithTracerProvider sets the tracer provider, defaults to otel.GetTracerProvider().
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider, defaults to global.MeterProvider().
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// Telemetry is the tracer and the meter of an instrumented component.
+type Telemetry struct {
+	Tracer trace.Tracer
+	Meter  metric.Meter
+}
+
+// New returns the tracer and the meter of golib from the providers of the options.
+func New(opts ...Option) *Telemetry {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.tracerProvider == nil {
+		cfg.tracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.meterProvider == nil {
+		cfg.meterProvider = global.MeterProvider()
+	}
+	return &Telemetry{
+		Tracer: cfg.tracerProvider.Tracer(InstrumentationName),
+		Meter:  cfg.meterProvider.Meter(InstrumentationName),
+	}
+}
+
+// RecordError records the error on the span and marks it as failed.
+func RecordError(span trace.Span, err error, attrs ...attribute.KeyValue) {
+	span.RecordError(err, trace.WithAttributes(attrs...))
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// The instruments below report the creation errors to the otel error handler and fall back to no-op instruments,
+// so that a misconfigured meter provider never breaks the instrumented component.
+
+// Int64Counter returns a counter of the meter.
+func (t *Telemetry) Int64Counter(name, description string, u unit.Unit) syncint64.Counter {
+	c, err := t.Meter.SyncInt64().Counter(name, instrument.WithDescription(description), instrument.WithUnit(u))
+	if err != nil {
+		otel.Handle(err)
+	}
+	if c == nil {
+		c, _ = metric.NewNoopMeter().SyncInt64().Counter(name)
+	}
+	return c
+}
+
+// Int64UpDownCounter returns an up-down counter of the meter, used as a synchronous gauge.
+func (t *Telemetry) Int64UpDownCounter(name, description string, u unit.Unit) syncint64.UpDownCounter {
+	c, err := t.Meter.SyncInt64().UpDownCounter(name, instrument.WithDescription(description), instrument.WithUnit(u))
+	if err != nil {
+		otel.Handle(err)
+	}
+	if c == nil {
+		c, _ = metric.NewNoopMeter().SyncInt64().UpDownCounter(name)
+	}
+	return c
+}
+
+// Float64Histogram returns a histogram of the meter.
+func (t *Telemetry) Float64Histogram(name, description string, u unit.Unit) syncfloat64.Histogram {
+	h, err := t.Meter.SyncFloat64().Histogram(name, instrument.WithDescription(description), instrument.WithUnit(u))
+	if err != nil {
+		otel.Handle(err)
+	}
+	if h == nil {
+		h, _ = metric.NewNoopMeter().SyncFloat64().Histogram(name)
+	}
+	return h
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,13 +14,20 @@ func GenerateRandomNameWithPrefix(prefix string) string {
 	return fmt.Sprintf("%s-%s", prefix, RandomString(5))
 }
 
+// random is seeded once, reseeding on every call returns the same strings for calls within the same clock tick.
+var random = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// RandomString returns a random string of lower case letters.
 func RandomString(len int) string {
 	var b strings.Builder
-	rand.Seed(time.Now().UnixNano())
-
 	b.Grow(len)
+	random.Lock()
+	defer random.Unlock()
 	for i := 0; i < len; i++ {
-		b.WriteByte(byte(65 + rand.Intn(25)))
+		b.WriteByte(byte('a' + random.Intn(26)))
 	}
-	return strings.ToLower(b.String())
+	return b.String()
 }